@@ -0,0 +1,191 @@
+// Package clock holds time controls, the clocks that keep them,
+// and how long a move can take under them. A Control is one of:
+//
+//	5m       sudden death: 5 minutes for the whole game
+//	5m+2s    Fischer: 5 minutes, and 2 seconds more after each move
+//	2s/move  2 seconds for each move, unused time doesn't carry over
+//
+// A player whose move takes longer than their clock has left
+// loses on time. The zero Control is no time control at all.
+package clock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Control is a time control.
+type Control struct {
+	Base      time.Duration // for the whole game
+	Increment time.Duration // added after each move
+	PerMove   time.Duration // for each move, if not zero
+}
+
+// Parse turns a time control like 5m, 5m+2s or 2s/move into a Control.
+// An empty string is no time control.
+func Parse(s string) (Control, error) {
+	var c Control
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return c, nil
+	}
+
+	if strings.HasSuffix(s, "/move") {
+		d, err := positive(strings.TrimSuffix(s, "/move"))
+		if err != nil {
+			return c, fmt.Errorf("time control %q: %w", s, err)
+		}
+		c.PerMove = d
+		return c, nil
+	}
+
+	base, increment, fischer := strings.Cut(s, "+")
+	d, err := positive(base)
+	if err != nil {
+		return c, fmt.Errorf("time control %q: %w", s, err)
+	}
+	c.Base = d
+	if fischer {
+		d, err = positive(increment)
+		if err != nil {
+			return c, fmt.Errorf("time control %q: %w", s, err)
+		}
+		c.Increment = d
+	}
+	return c, nil
+}
+
+func positive(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%v isn't a positive duration", d)
+	}
+	return d, nil
+}
+
+// None is true for the zero Control.
+func (c Control) None() bool {
+	return c == Control{}
+}
+
+func (c Control) String() string {
+	switch {
+	case c.None():
+		return "none"
+	case c.PerMove > 0:
+		return fmt.Sprintf("%v/move", c.PerMove)
+	case c.Increment > 0:
+		return fmt.Sprintf("%v+%v", c.Base, c.Increment)
+	}
+	return c.Base.String()
+}
+
+// Budget returns how long a move can take, given remaining time
+// on the clock, the time control's increment, and moveNumber, the
+// number of moves already made in the game. The mover's remaining
+// time gets shared out over the moves they have left, at most 13,
+// plus the increment they'll get back, but never more than
+// 19/20ths of what's left, so that overhead doesn't lose on time.
+func Budget(remaining, increment time.Duration, moveNumber int) time.Duration {
+	movesLeft := (25 - moveNumber + 1) / 2
+	if movesLeft < 1 {
+		movesLeft = 1
+	}
+	budget := remaining/time.Duration(movesLeft) + increment
+	if limit := remaining - remaining/20; budget > limit {
+		budget = limit
+	}
+	return budget
+}
+
+// Clock keeps one player's time under a Control.
+type Clock struct {
+	control   Control
+	remaining time.Duration
+	started   time.Time
+	flagged   bool
+}
+
+// New makes a Clock with control's starting time on it.
+// Under a time per move, a clock's time left is what
+// the latest move left over.
+func New(control Control) *Clock {
+	return &Clock{control: control, remaining: control.Base + control.PerMove}
+}
+
+// Control returns the time control the clock keeps.
+func (c *Clock) Control() Control {
+	return c.control
+}
+
+// Start starts the clock running, for a move.
+func (c *Clock) Start() {
+	c.started = time.Now()
+	if c.control.PerMove > 0 {
+		c.remaining = c.control.PerMove
+	}
+}
+
+// Stop stops the clock, takes the time since Start off it, and
+// adds the increment. Returns false if the move took longer than
+// the clock had left, a loss on time. Without a time control,
+// that never happens.
+func (c *Clock) Stop() bool {
+	if c.control.None() {
+		return true
+	}
+	c.remaining -= time.Since(c.started)
+	if c.remaining < 0 {
+		c.remaining = 0
+		c.flagged = true
+		return false
+	}
+	c.remaining += c.control.Increment
+	return true
+}
+
+// Flagged is true once a move took longer than the clock had left.
+func (c *Clock) Flagged() bool {
+	return c.flagged
+}
+
+// Remaining returns the time left on the clock.
+func (c *Clock) Remaining() time.Duration {
+	return c.remaining
+}
+
+// Budget returns how long the move about to be made can take,
+// moveNumber moves into the game, zero without a time control.
+func (c *Clock) Budget(moveNumber int) time.Duration {
+	if c.control.None() {
+		return 0
+	}
+	if c.control.PerMove > 0 {
+		return c.control.PerMove - c.control.PerMove/20
+	}
+	return Budget(c.remaining, c.control.Increment, moveNumber)
+}
+
+// Context returns a copy of parent with a deadline of now plus
+// the move's Budget, or parent itself without a time control,
+// so that players search the way ChooseMove would.
+func (c *Clock) Context(parent context.Context, moveNumber int) (context.Context, context.CancelFunc) {
+	if c.control.None() {
+		return parent, func() {}
+	}
+	return context.WithTimeout(parent, c.Budget(moveNumber))
+}
+
+// String formats the time left like 4:58.2
+func (c *Clock) String() string {
+	if c.control.None() {
+		return "-"
+	}
+	tenths := c.remaining.Round(100*time.Millisecond) / (100 * time.Millisecond)
+	return fmt.Sprintf("%d:%02d.%d", tenths/600, tenths/10%60, tenths%10)
+}
@@ -0,0 +1,62 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		s    string
+		want Control
+	}{
+		{"5m", Control{Base: 5 * time.Minute}},
+		{"5m+2s", Control{Base: 5 * time.Minute, Increment: 2 * time.Second}},
+		{"2s/move", Control{PerMove: 2 * time.Second}},
+		{" 2s/move ", Control{PerMove: 2 * time.Second}},
+		{"", Control{}},
+	} {
+		got, err := Parse(c.s)
+		if err != nil || got != c.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", c.s, got, err, c.want)
+		}
+	}
+
+	for _, s := range []string{"0s", "-1s", "5m+", "5m+0s", "+2s", "0s/move", "/move", "5"} {
+		if got, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", s, got)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	for _, remaining := range []time.Duration{0, time.Millisecond, time.Second, 5 * time.Minute} {
+		for _, increment := range []time.Duration{0, 2 * time.Second, time.Minute} {
+			for moveNumber := 0; moveNumber <= 25; moveNumber++ {
+				if budget := Budget(remaining, increment, moveNumber); budget < 0 || budget > remaining {
+					t.Errorf("Budget(%v, %v, %d) = %v, more than the time left", remaining, increment, moveNumber, budget)
+				}
+			}
+		}
+	}
+}
+
+func TestClockBudget(t *testing.T) {
+	for _, s := range []string{"5m", "5m+2s", "1s+1m", "2s/move"} {
+		control, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := New(control)
+		c.Start()
+		for moveNumber := 0; moveNumber <= 25; moveNumber++ {
+			if budget := c.Budget(moveNumber); budget <= 0 || budget > c.Remaining() {
+				t.Errorf("%s: Budget(%d) = %v with %v left", s, moveNumber, budget, c.Remaining())
+			}
+		}
+	}
+
+	if budget := New(Control{}).Budget(0); budget != 0 {
+		t.Errorf("no time control: Budget(0) = %v, want 0", budget)
+	}
+}
@@ -6,14 +6,20 @@ package main
  */
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"log"
 	"math"
 	"math/rand"
+	"os"
 	"strings"
 	"time"
 
+	"squava2/clock"
+	"squava2/game"
 	"squava2/players"
+	"squava2/solution"
 )
 
 const (
@@ -32,27 +38,68 @@ func main() {
 	mGames := flag.Float64("m", 14., "M player player effective games count")
 	uRating := flag.Float64("U", 1300., "U player initial rating")
 	uGames := flag.Float64("u", 14., "U player player effective games count")
-
+	rRating := flag.Float64("R", 1300., "R player initial rating")
+	rGames := flag.Float64("r", 14., "R player effective games count")
+	pRating := flag.Float64("P", 1300., "Perfect player initial rating")
+	pGames := flag.Float64("p", 14., "Perfect player effective games count")
+	dbName := flag.String("f", "", "solution database file, rate a perfect player (P) too")
+	tbName := flag.String("b", "", "endgame tablebase file (alpha/beta, MCTS)")
+	evalName := flag.String("E", "", "evaluator for players that use one, unless their specs say, like basic, avoid or block (alpha/beta, MCTS evaluator playouts)")
+	extra := flag.String("e", "", "more players to rate, space-separated specs like mcts:ucb1,c=1.1, rating 1300 over 14 games to start")
+	timeControl := flag.String("tc", "", "time control for all players, like 5m, 5m+2s or 2s/move, running out loses")
+
+	flag.Usage = usage
 	flag.Parse()
 
 	rand.Seed(time.Now().UTC().UnixNano())
 
-	nonInteractiveGames(*gameCount, *aRating, *aGames, *gRating, *gGames, *mRating, *mGames, *uRating, *uGames)
+	tc, err := clock.Parse(*timeControl)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var db *solution.DB
+	if *dbName != "" {
+		db = openDB(*dbName)
+	}
+
+	env := players.Env{DB: db}
+	if !tc.None() {
+		// MCTS searches until its move's time runs out
+		env.Defaults = "iters=0"
+	}
+	env.Defaults = strings.TrimPrefix(env.Defaults+evalOption(*evalName), ",")
+	if *tbName != "" {
+		env.Tablebase = loadTablebase(*tbName)
+	}
+
+	// Check specs before playing any games
+	extras := strings.Fields(*extra)
+	for _, spec := range extras {
+		if _, err := players.NewPlayer(spec, env); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	nonInteractiveGames(*gameCount, *aRating, *aGames, *gRating, *gGames, *mRating, *mGames, *uRating, *uGames, *rRating, *rGames, *pRating, *pGames, extras, env, tc)
 }
 
 type PlayerRating struct {
 	name           string
 	rating         float64
 	effectiveGames float64
+	player         players.Player
 }
 
-func nonInteractiveGames(gameCount int, aRating, aGames, gRating, gGames, mRating, mGames, uRating, uGames float64) {
+func nonInteractiveGames(gameCount int, aRating, aGames, gRating, gGames, mRating, mGames, uRating, uGames, rRating, rGames, pRating, pGames float64, extras []string, env players.Env, tc clock.Control) {
 
 	started := time.Now()
 
-	var playerList [4]PlayerRating
+	// The perfect player only plays if there's a database
+	// to look its moves up in.
+	playerList := make([]PlayerRating, 5, 6)
 
-	for i := 0; i < 4; i++ {
+	for i := 0; i < 5; i++ {
 		playerList[i].rating = 1300.
 		playerList[i].effectiveGames = 14.0
 	}
@@ -73,20 +120,42 @@ func nonInteractiveGames(gameCount int, aRating, aGames, gRating, gGames, mRatin
 	playerList[3].rating = uRating
 	playerList[3].effectiveGames = uGames
 
+	playerList[4].name = "R"
+	playerList[4].rating = rRating
+	playerList[4].effectiveGames = rGames
+
+	if env.DB != nil {
+		playerList = append(playerList, PlayerRating{
+			name:           "P",
+			rating:         pRating,
+			effectiveGames: pGames,
+		})
+	}
+
+	for _, spec := range extras {
+		playerList = append(playerList, PlayerRating{
+			name:           spec,
+			rating:         1300.,
+			effectiveGames: 14.,
+		})
+	}
+
 	for i := 0; i < gameCount; i++ {
 
-		firstChoice := rand.Intn(4)
-		secondChoice := rand.Intn(4)
+		firstChoice := rand.Intn(len(playerList))
+		secondChoice := rand.Intn(len(playerList))
 		for firstChoice == secondChoice {
-			secondChoice = rand.Intn(4)
+			secondChoice = rand.Intn(len(playerList))
 		}
 
-		first, second := createPlayers(
-			playerList[firstChoice].name,
-			playerList[secondChoice].name,
-			10,
-			false,
-		)
+		// Players get made the first time they play, and reset after that
+		for _, k := range []int{firstChoice, secondChoice} {
+			if playerList[k].player == nil {
+				playerList[k].player = createPlayer(playerList[k].name, env)
+			}
+			playerList[k].player.Reset()
+		}
+		first, second := playerList[firstChoice].player, playerList[secondChoice].player
 
 		moveCounter := 0
 
@@ -94,26 +163,39 @@ func nonInteractiveGames(gameCount int, aRating, aGames, gRating, gGames, mRatin
 		var values [25][2]int
 		var winner int
 
+		bd := game.NewPosition(MAXIMIZER)
+		clocks := [2]*clock.Clock{clock.New(tc), clock.New(tc)}
+
 		before := time.Now()
 
 		for moveCounter < 25 {
 
-			i, j, value, _ := first.ChooseMove()
+			i, j, value := timedMove(first, clocks[0], bd.MoveNumber())
 			moves[moveCounter][0], moves[moveCounter][1] = i, j
 			values[moveCounter][0] = value
 			second.MakeMove(i, j, MINIMIZER)
+			bd.MakeMove(game.Cell(i, j), MAXIMIZER)
 			moveCounter++
-			winner = first.FindWinner()
+			winner = bd.Outcome()
+			if clocks[0].Flagged() {
+				winner = MINIMIZER
+				break
+			}
 			if winner != 0 || moveCounter >= 25 {
 				break
 			}
 
-			i, j, value, _ = second.ChooseMove()
+			i, j, value = timedMove(second, clocks[1], bd.MoveNumber())
 			moves[moveCounter][0], moves[moveCounter][1] = i, j
 			values[moveCounter][1] = value
 			first.MakeMove(i, j, MINIMIZER)
+			bd.MakeMove(game.Cell(i, j), MINIMIZER)
 			moveCounter++
-			winner = -second.FindWinner() // main thinks second is minimizer
+			winner = bd.Outcome()
+			if clocks[1].Flagged() {
+				winner = MAXIMIZER
+				break
+			}
 			if winner != 0 {
 				break
 			}
@@ -136,6 +218,9 @@ func nonInteractiveGames(gameCount int, aRating, aGames, gRating, gGames, mRatin
 			firstScore = 0.5
 			secondScore = 0.5
 		}
+		if clocks[0].Flagged() || clocks[1].Flagged() {
+			winning += " on time"
+		}
 
 		previousFirstRating := playerList[firstChoice].rating
 		playerList[firstChoice].effectiveGames++
@@ -179,34 +264,61 @@ func We(R, Ri float64) float64 {
 	return 1.0 / (1.0 + math.Pow(10., exponent))
 }
 
-func createPlayers(firstType, secondType string, maxDepth int, deterministic bool) (players.Player, players.Player) {
+// timedMove has p choose a move, with c running, and with
+// the move's share of c's time to choose it in.
+func timedMove(p players.Player, c *clock.Clock, moveNumber int) (x, y, value int) {
+	ctx, cancel := c.Context(context.Background(), moveNumber)
+	defer cancel()
+	c.Start()
+	x, y, value, _, _ = p.ChooseMoveContext(ctx)
+	c.Stop()
+	return x, y, value
+}
 
-	firstType = strings.ToUpper(firstType)
-	secondType = strings.ToUpper(secondType)
+// createPlayer makes the player spec describes.
+// The specs got checked before any games.
+func createPlayer(spec string, env players.Env) players.Player {
+	player, err := players.NewPlayer(spec, env)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return player
+}
 
-	return createPlayer(firstType, maxDepth, deterministic, 500000),
-		createPlayer(secondType, maxDepth, deterministic, 500000)
+// openDB opens the solution database that perfect players look up moves in.
+func openDB(fileName string) *solution.DB {
+	db, err := solution.ReadDB(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
 }
 
-func createPlayer(typ string, maxDepth int, deterministic bool, iterations int) players.Player {
-
-	typ = strings.ToUpper(typ)
-
-	switch typ {
-	case "A":
-		return players.NewAlphaBeta(deterministic, maxDepth)
-	case "G":
-		ab := players.NewAlphaBeta(deterministic, maxDepth)
-		ab.SetAvoid()
-		return ab
-	case "M":
-		mcts := players.NewMCTS(iterations)
-		return mcts
-	case "U":
-		mcts := players.NewMCTS(iterations)
-		mcts.SetUCB1()
-		return mcts
+// loadTablebase reads the endgame tablebase that alpha/beta
+// and MCTS players look up positions in.
+func loadTablebase(fileName string) *solution.Tablebase {
+	tb, err := solution.LoadTablebase(fileName)
+	if err != nil {
+		log.Fatal(err)
 	}
+	return tb
+}
+
+// evalOption returns the default option that has players value
+// positions with the Evaluator called name, none if name is empty.
+func evalOption(name string) string {
+	if name == "" {
+		return ""
+	}
+	if _, err := players.NewEvaluator(name, nil); err != nil {
+		log.Fatal(err)
+	}
+	return ",eval=" + name
+}
 
-	return nil
+// usage adds the player types and their options to the flags.
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintf(flag.CommandLine.Output(), "\nPlayer types and options:\n%s", players.Usage())
 }
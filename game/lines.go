@@ -0,0 +1,121 @@
+package game
+
+// Cells are numbered 0 through 24, row-major: cell 5*x+y
+// is row x, column y. Every line that decides a game is
+// listed here once, turned into a bitboard mask, and indexed
+// by cell in init() so that code interested in a single move
+// only looks at the lines running through that move's cell.
+
+// Quads are the 28 4-in-a-row lines. Filling one wins.
+var Quads = [28][4]int{
+	{0, 1, 2, 3},
+	{0, 5, 10, 15},
+	{0, 6, 12, 18},
+	{1, 2, 3, 4},
+	{1, 6, 11, 16},
+	{1, 7, 13, 19},
+	{2, 7, 12, 17},
+	{3, 8, 13, 18},
+	{3, 7, 11, 15},
+	{4, 9, 14, 19},
+	{4, 8, 12, 16},
+	{5, 6, 7, 8},
+	{5, 10, 15, 20},
+	{5, 11, 17, 23},
+	{6, 7, 8, 9},
+	{6, 11, 16, 21},
+	{6, 12, 18, 24},
+	{7, 12, 17, 22},
+	{8, 13, 18, 23},
+	{8, 12, 16, 20},
+	{9, 14, 19, 24},
+	{9, 13, 17, 21},
+	{10, 11, 12, 13},
+	{11, 12, 13, 14},
+	{15, 16, 17, 18},
+	{16, 17, 18, 19},
+	{20, 21, 22, 23},
+	{21, 22, 23, 24},
+}
+
+// Triplets are the 48 3-in-a-row lines. Filling one loses,
+// unless the same move also fills a quad.
+var Triplets = [48][3]int{
+	{0, 1, 2},
+	{0, 5, 10},
+	{0, 6, 12},
+	{1, 2, 3},
+	{1, 6, 11},
+	{1, 7, 13},
+	{2, 3, 4},
+	{2, 7, 12},
+	{2, 8, 14},
+	{2, 6, 10},
+	{3, 8, 13},
+	{3, 7, 11},
+	{4, 9, 14},
+	{4, 8, 12},
+	{5, 6, 7},
+	{5, 10, 15},
+	{5, 11, 17},
+	{6, 7, 8},
+	{6, 11, 16},
+	{6, 12, 18},
+	{7, 8, 9},
+	{7, 12, 17},
+	{7, 13, 19},
+	{7, 11, 15},
+	{8, 13, 18},
+	{8, 12, 16},
+	{9, 14, 19},
+	{9, 13, 17},
+	{10, 11, 12},
+	{10, 15, 20},
+	{10, 16, 22},
+	{11, 12, 13},
+	{11, 16, 21},
+	{11, 17, 23},
+	{12, 13, 14},
+	{12, 17, 22},
+	{12, 18, 24},
+	{12, 16, 20},
+	{13, 18, 23},
+	{13, 17, 21},
+	{14, 19, 24},
+	{14, 18, 22},
+	{15, 16, 17},
+	{16, 17, 18},
+	{17, 18, 19},
+	{20, 21, 22},
+	{21, 22, 23},
+	{22, 23, 24},
+}
+
+// QuadMasks and TripletMasks are Quads and Triplets as
+// bitboards, bit n set for cell n.
+var QuadMasks [28]uint32
+var TripletMasks [48]uint32
+
+// QuadMasksAt and TripletMasksAt hold, for each cell, the
+// masks of the lines that run through that cell.
+var QuadMasksAt [25][]uint32
+var TripletMasksAt [25][]uint32
+
+func init() {
+	for i, quad := range Quads {
+		for _, cell := range quad {
+			QuadMasks[i] |= 1 << cell
+		}
+		for _, cell := range quad {
+			QuadMasksAt[cell] = append(QuadMasksAt[cell], QuadMasks[i])
+		}
+	}
+	for i, triplet := range Triplets {
+		for _, cell := range triplet {
+			TripletMasks[i] |= 1 << cell
+		}
+		for _, cell := range triplet {
+			TripletMasksAt[cell] = append(TripletMasksAt[cell], TripletMasks[i])
+		}
+	}
+}
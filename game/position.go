@@ -0,0 +1,256 @@
+// Package game holds the rules of squava: a position, legal
+// moves, making and unmaking moves, and deciding who, if
+// anybody, won. Players, and the driver programs that referee
+// games between players, all use it, so there's exactly one
+// idea of what a win or a loss is.
+package game
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// Manifest constants to improve understanding
+const (
+	MAXIMIZER = 1
+	MINIMIZER = -1
+	UNSET     = 0
+)
+
+// AllCells has a bit set for each of the 25 cells
+const AllCells uint32 = 1<<25 - 1
+
+// Position is a squava board, which player moves next,
+// and the moves made so far, in order. The board is two
+// bitboards, one per player, bit n set if that player
+// marked cell n.
+type Position struct {
+	marks      [2]uint32
+	hashes     [8]uint64 // Zobrist hash of each symmetry of the board
+	toMove     int
+	moveNumber int
+	history    [25]int
+}
+
+// side turns MAXIMIZER into 0 and MINIMIZER into 1,
+// the indexes into Position.marks
+func side(player int) int {
+	return (1 - player) >> 1
+}
+
+// NewPosition returns an empty board with toMove
+// (MAXIMIZER or MINIMIZER) making the first move.
+func NewPosition(toMove int) *Position {
+	p := &Position{}
+	p.Reset(toMove)
+	return p
+}
+
+// Reset empties the board, toMove makes the next move.
+func (p *Position) Reset(toMove int) {
+	*p = Position{toMove: toMove}
+}
+
+// Cell turns <x,y> coords into a cell number
+func Cell(x, y int) int {
+	return 5*x + y
+}
+
+// Coords turns a cell number into <x,y> coords
+func Coords(cell int) (x, y int) {
+	return cell / 5, cell % 5
+}
+
+// FormatMoves formats cells as x,y coords, separated by
+// spaces, the way package mover reads partial games.
+func FormatMoves(cells []int) string {
+	moves := make([]string, len(cells))
+	for i, cell := range cells {
+		x, y := Coords(cell)
+		moves[i] = fmt.Sprintf("%d,%d", x, y)
+	}
+	return strings.Join(moves, " ")
+}
+
+// At returns the mark (MAXIMIZER, MINIMIZER or UNSET) in cell
+func (p *Position) At(cell int) int {
+	bit := uint32(1) << cell
+	switch {
+	case p.marks[0]&bit != 0:
+		return MAXIMIZER
+	case p.marks[1]&bit != 0:
+		return MINIMIZER
+	}
+	return UNSET
+}
+
+// Marks returns the bitboard of cells that player has marked.
+func (p *Position) Marks(player int) uint32 {
+	return p.marks[side(player)]
+}
+
+// Empty returns the bitboard of unmarked cells.
+func (p *Position) Empty() uint32 {
+	return AllCells &^ (p.marks[0] | p.marks[1])
+}
+
+// ToMove returns the player that makes the next move.
+func (p *Position) ToMove() int {
+	return p.toMove
+}
+
+// SetToMove makes player the next to move, no matter
+// who made the last move.
+func (p *Position) SetToMove(player int) {
+	p.toMove = player
+}
+
+// MoveNumber returns the count of moves made so far.
+func (p *Position) MoveNumber() int {
+	return p.moveNumber
+}
+
+// History returns the cells marked so far, in the order marked.
+func (p *Position) History() []int {
+	return p.history[:p.moveNumber]
+}
+
+// LastMove returns the most recently marked cell, -1 on an empty board
+func (p *Position) LastMove() int {
+	if p.moveNumber == 0 {
+		return -1
+	}
+	return p.history[p.moveNumber-1]
+}
+
+// EmptyCells returns all unmarked cells, whether or not
+// the game has already been decided.
+func (p *Position) EmptyCells() []int {
+	cells := make([]int, 0, 25-p.moveNumber)
+	for empty := p.Empty(); empty != 0; empty &= empty - 1 {
+		cells = append(cells, bits.TrailingZeros32(empty))
+	}
+	return cells
+}
+
+// LegalMoves returns the cells the player to move could
+// mark. There are none once somebody has won or lost.
+func (p *Position) LegalMoves() []int {
+	if p.Outcome() != UNSET {
+		return nil
+	}
+	return p.EmptyCells()
+}
+
+// MoverView replays history, the moves of a game so far, onto a new
+// board the way the player to move next sees it: that player's marks
+// are MAXIMIZER's, the first mover's if an even number of moves got
+// made, and MAXIMIZER moves next.
+func MoverView(history []int) *Position {
+	p := NewPosition(MAXIMIZER)
+	mark := MAXIMIZER
+	if len(history)%2 == 1 {
+		mark = MINIMIZER
+	}
+	for _, cell := range history {
+		p.MakeMove(cell, mark)
+		mark = -mark
+	}
+	return p
+}
+
+// Make marks cell for the player to move.
+func (p *Position) Make(cell int) {
+	p.MakeMove(cell, p.toMove)
+}
+
+// MakeMove marks cell for player, whether or not it's player's
+// turn, so that a driver program can set up any board it wants.
+// The other player moves next.
+func (p *Position) MakeMove(cell int, player int) {
+	p.marks[side(player)] |= 1 << cell
+	p.hashMark(cell, player)
+	p.history[p.moveNumber] = cell
+	p.moveNumber++
+	p.SetToMove(-player)
+}
+
+// Unmake takes back the most recent move. The player who
+// made that move is the player to move again.
+func (p *Position) Unmake() {
+	p.moveNumber--
+	cell := p.history[p.moveNumber]
+	player := p.At(cell)
+	p.marks[side(player)] &^= 1 << cell
+	p.hashMark(cell, player)
+	p.SetToMove(player)
+}
+
+// Outcome returns MAXIMIZER or MINIMIZER if somebody won,
+// UNSET if nobody has. Completing a 4-in-a-row wins even if
+// the same move completes a 3-in-a-row.
+func (p *Position) Outcome() int {
+	x, o := p.marks[0], p.marks[1]
+	for _, m := range QuadMasks {
+		switch m {
+		case x & m:
+			return MAXIMIZER
+		case o & m:
+			return MINIMIZER
+		}
+	}
+	for _, m := range TripletMasks {
+		switch m {
+		case x & m:
+			return MINIMIZER
+		case o & m:
+			return MAXIMIZER
+		}
+	}
+	return UNSET
+}
+
+// OutcomeAt is Outcome, but only looks at lines through cell.
+// Searches use it to decide the move just made to cell, on a
+// board where nobody had won or lost before that move.
+func (p *Position) OutcomeAt(cell int) int {
+	player := p.At(cell)
+	if player == UNSET {
+		return UNSET
+	}
+	mine := p.marks[side(player)]
+	for _, m := range QuadMasksAt[cell] {
+		if mine&m == m {
+			return player
+		}
+	}
+	for _, m := range TripletMasksAt[cell] {
+		if mine&m == m {
+			return -player
+		}
+	}
+	return UNSET
+}
+
+// Finished is true if somebody won, or all 25 cells are marked.
+func (p *Position) Finished() bool {
+	return p.moveNumber >= 25 || p.Outcome() != UNSET
+}
+
+// String gives a human readable board, X for MAXIMIZER,
+// O for MINIMIZER.
+func (p *Position) String() string {
+	buf := &strings.Builder{}
+	buf.WriteString("   0 1 2 3 4\n")
+	for cell := 0; cell < 25; cell++ {
+		if (cell % 5) == 0 {
+			fmt.Fprintf(buf, "%d  ", cell/5)
+		}
+		fmt.Fprintf(buf, "%c ", "O_X"[p.At(cell)+1])
+		if (cell % 5) == 4 {
+			buf.WriteString("\n")
+		}
+	}
+	return buf.String()
+}
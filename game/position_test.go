@@ -0,0 +1,86 @@
+package game
+
+import "testing"
+
+func TestMoverViewOddMove(t *testing.T) {
+	// X marks 0,0, O is to move
+	view := MoverView([]int{Cell(0, 0)})
+	if got := view.At(Cell(0, 0)); got != MINIMIZER {
+		t.Errorf("X's cell 0,0 is %d in O's view, want the opponent's, %d", got, MINIMIZER)
+	}
+	if got := view.ToMove(); got != MAXIMIZER {
+		t.Errorf("%d to move in O's view, want %d", got, MAXIMIZER)
+	}
+}
+
+func TestMoverViewEvenMove(t *testing.T) {
+	// X marks 0,0, O marks 2,2, X is to move
+	view := MoverView([]int{Cell(0, 0), Cell(2, 2)})
+	if got := view.At(Cell(0, 0)); got != MAXIMIZER {
+		t.Errorf("X's cell 0,0 is %d in X's view, want its own, %d", got, MAXIMIZER)
+	}
+	if got := view.At(Cell(2, 2)); got != MINIMIZER {
+		t.Errorf("O's cell 2,2 is %d in X's view, want the opponent's, %d", got, MINIMIZER)
+	}
+	if got := view.ToMove(); got != MAXIMIZER {
+		t.Errorf("%d to move in X's view, want %d", got, MAXIMIZER)
+	}
+}
+
+func TestSymmetryInverses(t *testing.T) {
+	for s := range Symmetries {
+		inverse := Inverse(s)
+		for cell := 0; cell < 25; cell++ {
+			if got := Symmetries[inverse][Symmetries[s][cell]]; got != cell {
+				t.Errorf("symmetry %d then its inverse %d moves cell %d to %d", s, inverse, cell, got)
+			}
+		}
+		marks := uint32(0x1b3a5c7) & AllCells
+		if got := TransformMarks(inverse, TransformMarks(s, marks)); got != marks {
+			t.Errorf("symmetry %d then its inverse %d turns marks %#x into %#x", s, inverse, marks, got)
+		}
+	}
+}
+
+func TestCanonicalHashOfImages(t *testing.T) {
+	moves := []int{Cell(0, 1), Cell(2, 2), Cell(3, 4), Cell(1, 3), Cell(4, 0)}
+	var want uint64
+	var wantMarks [2]uint32
+	for s := range Symmetries {
+		image := NewPosition(MAXIMIZER)
+		for _, cell := range moves {
+			image.Make(Symmetries[s][cell])
+		}
+		hash, sym := image.CanonicalHash()
+		// sym turns every image into the same board
+		marks := [2]uint32{TransformMarks(sym, image.Marks(MAXIMIZER)), TransformMarks(sym, image.Marks(MINIMIZER))}
+		if s == 0 {
+			want, wantMarks = hash, marks
+			continue
+		}
+		if hash != want {
+			t.Errorf("image by symmetry %d has canonical hash %#x, want %#x", s, hash, want)
+		}
+		if marks != wantMarks {
+			t.Errorf("image by symmetry %d turned by symmetry %d has marks %#x, want %#x", s, sym, marks, wantMarks)
+		}
+	}
+}
+
+func TestMoveClassesOfEmptyBoard(t *testing.T) {
+	// Corners, centers of edges, cells next to corners on
+	// edges, inner corners, inner edge centers, and the center
+	classes := NewPosition(MAXIMIZER).MoveClasses()
+	if len(classes) != 6 {
+		t.Errorf("%d classes of moves on the empty board, want 6: %v", len(classes), classes)
+	}
+	var cells uint32
+	for _, class := range classes {
+		for _, cell := range class {
+			cells |= 1 << cell
+		}
+	}
+	if cells != AllCells {
+		t.Errorf("classes of moves on the empty board leave out cells %#x", AllCells&^cells)
+	}
+}
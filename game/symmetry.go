@@ -0,0 +1,159 @@
+package game
+
+// The 5x5 board has 8 symmetries: 4 rotations, each with
+// or without a reflection. Positions that one symmetry turns into
+// another have the same value, as do moves that a symmetry
+// of the position turns into one another.
+
+// Symmetries[s][cell] is the cell that symmetry s moves cell to.
+// Symmetry 0 is the identity.
+var Symmetries [8][25]int
+
+var inverses [8]int
+
+// transformBytes[s][i][b] is byte i of a bitboard, holding b,
+// moved by symmetry s. Makes TransformMarks 4 lookups.
+var transformBytes [8][4][256]uint32
+
+func init() {
+	for s := range Symmetries {
+		for cell := range Symmetries[s] {
+			x, y := Coords(cell)
+			if s >= 4 {
+				y = 4 - y // reflect
+			}
+			for r := 0; r < s%4; r++ {
+				x, y = y, 4-x // rotate 90 degrees
+			}
+			Symmetries[s][cell] = Cell(x, y)
+		}
+	}
+	for s := range Symmetries {
+		for i := range transformBytes[s] {
+			for b := range transformBytes[s][i] {
+				for bit := 0; bit < 8 && 8*i+bit < 25; bit++ {
+					if b&(1<<bit) != 0 {
+						transformBytes[s][i][b] |= 1 << Symmetries[s][8*i+bit]
+					}
+				}
+			}
+		}
+		for t := range Symmetries {
+			if Symmetries[t][Symmetries[s][0]] == 0 && Symmetries[t][Symmetries[s][1]] == 1 {
+				inverses[s] = t
+			}
+		}
+	}
+}
+
+// Inverse returns the symmetry that undoes symmetry s.
+func Inverse(s int) int {
+	return inverses[s]
+}
+
+// TransformMarks moves every cell set in marks by symmetry s.
+func TransformMarks(s int, marks uint32) uint32 {
+	t := &transformBytes[s]
+	return t[0][marks&0xff] | t[1][(marks>>8)&0xff] | t[2][(marks>>16)&0xff] | t[3][(marks>>24)&0xff]
+}
+
+// Key packs the board into 50 bits: cells marked by the player
+// to move in the low 25 bits, the other player's cells in the high
+// 25 bits. Squava treats both players alike, so positions with the
+// same Key have the same value for the player to move.
+func (p *Position) Key() uint64 {
+	mine, theirs := p.Marks(p.toMove), p.Marks(-p.toMove)
+	return uint64(mine) | uint64(theirs)<<25
+}
+
+// CanonicalKey is the smallest Key of any symmetry of the
+// position. Positions that a symmetry turns into one
+// another have the same CanonicalKey, others never do.
+func (p *Position) CanonicalKey() uint64 {
+	return Canonical(p.Key())
+}
+
+// Canonical returns the smallest Key of any symmetry
+// of the position with Key key.
+func Canonical(key uint64) uint64 {
+	mine, theirs := uint32(key)&AllCells, uint32(key>>25)&AllCells
+	for s := 1; s < len(Symmetries); s++ {
+		k := uint64(TransformMarks(s, mine)) | uint64(TransformMarks(s, theirs))<<25
+		if k < key {
+			key = k
+		}
+	}
+	return key
+}
+
+// FromKey makes a position out of a Key, player to move.
+// Its history has the marked cells in cell order, not
+// in the order the moves got made.
+func FromKey(key uint64, player int) *Position {
+	p := NewPosition(player)
+	mine := uint32(key) & AllCells
+	theirs := uint32(key>>25) & AllCells
+	for cell := 0; cell < 25; cell++ {
+		switch {
+		case mine&(1<<cell) != 0:
+			p.MakeMove(cell, player)
+		case theirs&(1<<cell) != 0:
+			p.MakeMove(cell, -player)
+		}
+	}
+	p.SetToMove(player)
+	return p
+}
+
+// CanonicalHash returns the same hash for all positions that a
+// symmetry turns into one another, and the symmetry that turns
+// this position into the one whose Zobrist hash that is.
+func (p *Position) CanonicalHash() (hash uint64, sym int) {
+	hash = p.hashes[0]
+	for s := 1; s < len(p.hashes); s++ {
+		if p.hashes[s] < hash {
+			hash, sym = p.hashes[s], s
+		}
+	}
+	return hash ^ p.sideKey(), sym
+}
+
+// Stabilizer returns the symmetries that leave the position
+// unchanged. The identity, symmetry 0, is always one of them.
+func (p *Position) Stabilizer() []int {
+	syms := []int{0}
+	for s := 1; s < len(Symmetries); s++ {
+		if p.hashes[s] == p.hashes[0] &&
+			TransformMarks(s, p.marks[0]) == p.marks[0] &&
+			TransformMarks(s, p.marks[1]) == p.marks[1] {
+			syms = append(syms, s)
+		}
+	}
+	return syms
+}
+
+// MoveClasses groups empty cells into classes of moves that
+// lead to equivalent positions. Each class starts with its
+// lowest-numbered cell, and classes are in order of that cell.
+// Early in a game, when the position has symmetries, there
+// are fewer classes than empty cells.
+func (p *Position) MoveClasses() [][]int {
+	stabilizer := p.Stabilizer()
+	var classes [][]int
+	var seen uint32
+	for _, cell := range p.EmptyCells() {
+		if seen&(1<<cell) != 0 {
+			continue
+		}
+		var class []int
+		for _, s := range stabilizer {
+			image := Symmetries[s][cell]
+			if seen&(1<<image) == 0 {
+				seen |= 1 << image
+				class = append(class, image)
+			}
+		}
+		classes = append(classes, class)
+	}
+	return classes
+}
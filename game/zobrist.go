@@ -0,0 +1,49 @@
+package game
+
+// Zobrist hashing: every (player, cell) pair gets a random
+// 64-bit key, and a position's hash is the XOR of the keys of
+// all its marks, and of minimizerKey if MINIMIZER moves next.
+// A Position keeps the hash of its marks up to date, and of the
+// marks of each of its 8 symmetries, so that CanonicalHash is cheap.
+
+var zobristKeys [2][25]uint64
+var minimizerKey uint64
+
+func init() {
+	// Fixed seed, so that hashes are the same from run to run.
+	state := uint64(0x5155415641325a42)
+	next := func() uint64 {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		return z ^ (z >> 31)
+	}
+	for s := range zobristKeys {
+		for cell := range zobristKeys[s] {
+			zobristKeys[s][cell] = next()
+		}
+	}
+	minimizerKey = next()
+}
+
+// Hash returns the Zobrist hash of the board and player to move.
+func (p *Position) Hash() uint64 {
+	return p.hashes[0] ^ p.sideKey()
+}
+
+func (p *Position) sideKey() uint64 {
+	if p.toMove == MINIMIZER {
+		return minimizerKey
+	}
+	return 0
+}
+
+// hashMark adds or removes player's mark in cell from all the hashes.
+func (p *Position) hashMark(cell int, player int) {
+	keys := &zobristKeys[side(player)]
+	for s := range p.hashes {
+		p.hashes[s] ^= keys[Symmetries[s][cell]]
+	}
+}
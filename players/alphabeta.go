@@ -1,12 +1,19 @@
 package players
 
 import (
-	"fmt"
+	"context"
+	"math/bits"
 	"math/rand"
+	"sort"
 	"strings"
-)
+	"sync"
+	"sync/atomic"
+	"time"
 
-type board [5][5]int
+	"squava2/clock"
+	"squava2/game"
+	"squava2/solution"
+)
 
 // Semantically meaningful constant names
 const (
@@ -15,51 +22,153 @@ const (
 )
 
 type AlphaBeta struct {
-	bd            *board
-	moveCounter   int
+	pos           *game.Position
 	name          string
 	leafNodeCount int
+	nodes         int // alphaBeta calls, leaves included
+	tableHits     int
+	tableMisses   int
 	maxDepth      int
+	fixedDepth    int // if non-zero, maxDepth no matter the move number
 	deterministic bool
-	boardValue    func(*AlphaBeta, int, int, int, int) (bool, int)
+	eval          Evaluator
+	table         *transTable
+	tablebase     *solution.Tablebase
+	workers       int // goroutines searching root moves, 0 if never set
+
+	pvs        bool  // null-window searches after a position's first move
+	aspiration int   // if not 0, root windows this far either side of the previous depth's value
+	recordPV   bool  // find the principal variation after a search
+	pv         []int // the latest one, chosen move first
+	mtdf       bool  // value root moves with MTD(f), see mtdf.go
+	guess      int   // MTD(f)'s first guess, the latest root move's value
+
+	// Move ordering, see ordering.go
+	plainOrder bool
+	killers    [26][killerCount]int // by ply
+	history    [2][25]int           // by player, MINIMIZER's first
+
+	// Iterative deepening, if either of these is non-zero
+	moveTime  time.Duration   // per move
+	clock     time.Duration   // remaining for the rest of the game
+	gameClock time.Duration   // clock at the start of a game
+	deadline  time.Time       // zero if there's only done to watch
+	done      <-chan struct{} // ChooseMoveContext's ctx.Done()
+	nodeCount int             // nodes since deadline got set
+	timeLimit bool            // true while searches must watch deadline
+	stopped   bool            // deadline passed, abandon search
+
+	// The deepest depth the latest iterative deepening finished, and
+	// the root moves in order of their values at that depth
+	idDepth  int
+	idOrder  []int
+	idValues [25]int
+
+	// Pondering: searching the predicted reply while the opponent thinks
+	ponderCancel context.CancelFunc
+	ponderDone   chan struct{}
+	predicted    int          // the reply pondering searched after
+	pondered     bool         // until the opponent's move gets made
+	ponderHit    bool         // the opponent made the predicted reply
+	ponderMove   ponderResult // what searching after predicted found
+	ponderHits   int
+	ponderMisses int
 }
 
-// Arrays of losing triplets and winning quads, indexed
-// by <x,y> coords of all pairs composing each of the quads
-// or triplets. Makes deltaValue() a lot more efficient
-var indexedLosingTriplets [5][5][][][]int
-var indexedWinningQuads [5][5][][][]int
-var indexedCalcs = false
-
-func calculateIndexedMatrices() {
-	// Set up for use by deltaValue()
-	for _, triplet := range losingTriplets {
-		for _, pair := range triplet {
-			indexedLosingTriplets[pair[0]][pair[1]] = append(indexedLosingTriplets[pair[0]][pair[1]], triplet)
-		}
-	}
-	for _, quad := range winningQuads {
-		for _, pair := range quad {
-			indexedWinningQuads[pair[0]][pair[1]] = append(
-				indexedWinningQuads[pair[0]][pair[1]], quad)
-		}
-	}
+// ponderResult is the move pondering chose, ChooseMoveContext's
+// results, the search's table stats, and the deepest depth it
+// finished, with root moves in order of their values at that depth.
+type ponderResult struct {
+	x, y, value, leaves, nodes int
+	complete                   bool
+	tableHits, tableMisses     int
+	depth                      int
+	order                      []int
+	values                     [25]int
 }
 
+// DefaultTableSize is the transposition table memory budget, in bytes,
+// an AlphaBeta player gets unless SetTableSize says otherwise.
+const DefaultTableSize = 16 << 20
+
 func NewAlphaBeta(deterministic bool, maxdepth int) *AlphaBeta {
-	if !indexedCalcs {
-		calculateIndexedMatrices()
-		indexedCalcs = true
-	}
 	return &AlphaBeta{
-		bd:            new(board),
+		pos:           game.NewPosition(MAXIMIZER),
 		name:          "AlphaBeta",
 		maxDepth:      maxdepth,
 		deterministic: deterministic,
-		boardValue:    deltaValue,
+		eval:          EvaluatorFunc(deltaValue),
+		table:         newTransTable(DefaultTableSize),
 	}
 }
 
+// SetTableSize replaces the transposition table with an empty
+// one that fits in budget bytes. A budget of 0 turns it off.
+func (p *AlphaBeta) SetTableSize(budget int) {
+	p.table = newTransTable(budget)
+}
+
+// TableStats returns the transposition table hits and misses
+// of the most recent ChooseMove, to go with its leaf node count.
+func (p *AlphaBeta) TableStats() (hits, misses int) {
+	return p.tableHits, p.tableMisses
+}
+
+// Nodes returns the number of positions the most recent
+// ChooseMove searched below the root, leaves included.
+func (p *AlphaBeta) Nodes() int {
+	return p.nodes
+}
+
+// SetPVS has searches use principal variation search: each
+// position's first move gets the full window, and the rest a
+// null window, to show that they're no better, and only if one
+// is better, the full window again to find out how much better.
+func (p *AlphaBeta) SetPVS(on bool) {
+	p.pvs = on
+}
+
+// SetAspiration has searches deepen iteratively, searching the
+// root moves at each depth with a window width either side of the
+// previous depth's value, and again with a wider window if that
+// doesn't hold the value. A width of 0 turns it off.
+func (p *AlphaBeta) SetAspiration(width int) {
+	p.aspiration = width
+}
+
+// SetRecordPV has ChooseMove find the principal variation,
+// the line of play it expects, for PrincipalVariation.
+func (p *AlphaBeta) SetRecordPV(on bool) {
+	p.recordPV = on
+}
+
+// PrincipalVariation returns the line of play the most recent
+// ChooseMove expects, its move first, then the best replies as far
+// as the transposition table has exact values for them. It's nil
+// unless SetRecordPV turned recording on.
+func (p *AlphaBeta) PrincipalVariation() []int {
+	return p.pv
+}
+
+// SetWorkers has workers goroutines search root moves in parallel,
+// each taking the next unsearched root move, all sharing the
+// transposition table. Static values depend on the order moves
+// got made, so the value a table entry holds for a position depends
+// on which line reached it first. A deterministic player with more
+// than one worker gives up the table's cutoffs, and only uses the
+// table to order moves, so that its values at a fixed depth come out
+// the same with any number of workers. That makes it search more
+// nodes than with one worker, which searches like no workers at all.
+func (p *AlphaBeta) SetWorkers(workers int) {
+	p.workers = workers
+}
+
+// SetTablebase has searches look up positions with few enough
+// empty cells in tb, instead of searching them. nil turns it off.
+func (p *AlphaBeta) SetTablebase(tb *solution.Tablebase) {
+	p.tablebase = tb
+}
+
 // Name of the player
 func (p *AlphaBeta) Name() string {
 	return p.name
@@ -68,286 +177,732 @@ func (p *AlphaBeta) Name() string {
 // MakeMove changes internal board representation,
 // making opposing player's move
 func (p *AlphaBeta) MakeMove(x, y int, player int) {
-	p.bd[x][y] = player
-	p.moveCounter++
+	cell := game.Cell(x, y)
+	p.StopPondering()
+	p.ponderHit = false
+	if p.pondered && player == MINIMIZER {
+		if cell == p.predicted {
+			p.ponderHits++
+			p.ponderHit = true
+		} else {
+			p.ponderMisses++
+		}
+	}
+	p.pondered = false
+	p.pos.MakeMove(cell, player)
+}
+
+// SetMoveTime has ChooseMove search deeper and deeper until
+// budget runs out, instead of to a depth set by move number.
+func (p *AlphaBeta) SetMoveTime(budget time.Duration) {
+	p.moveTime = budget
+}
+
+// SetClock has ChooseMove search deeper and deeper, budgeting
+// the remaining time for the rest of the game over the moves it
+// still has to make. Each ChooseMove deducts its time from the clock.
+func (p *AlphaBeta) SetClock(remaining time.Duration) {
+	p.clock = remaining
+	p.gameClock = remaining
+}
+
+// UnmakeMove takes back the last move made, by either player.
+// The time it took doesn't go back on the clock.
+func (p *AlphaBeta) UnmakeMove() {
+	p.StopPondering()
+	p.pondered, p.ponderHit = false, false
+	if p.pos.MoveNumber() > 0 {
+		p.pos.Unmake()
+	}
+}
+
+// Reset empties the board and sets the clock back to what SetClock
+// set it to, for a new game. The transposition table keeps its
+// entries, they're good in any game.
+func (p *AlphaBeta) Reset() {
+	p.StopPondering()
+	p.pondered, p.ponderHit = false, false
+	p.pos.Reset(MAXIMIZER)
+	p.guess = 0
+	p.clock = p.gameClock
+}
+
+// SetPosition makes the board a copy of pos, sideToMove to move.
+func (p *AlphaBeta) SetPosition(pos *game.Position, sideToMove int) {
+	p.StopPondering()
+	p.pondered, p.ponderHit = false, false
+	*p.pos = *pos
+	p.pos.SetToMove(sideToMove)
+}
+
+// moveBudget returns how long this move can take
+func (p *AlphaBeta) moveBudget() time.Duration {
+	if p.moveTime > 0 {
+		return p.moveTime
+	}
+	return clock.Budget(p.clock, 0, p.pos.MoveNumber())
+}
+
+// SetDepth has searches look depth moves ahead. A depth
+// of 0 has the depth depend on how far along the game is.
+func (p *AlphaBeta) SetDepth(depth int) {
+	p.fixedDepth = depth
 }
 
 // setDepth changes the max recursion depth based
 // on how far along the game has gotten.
 func (p *AlphaBeta) setDepth() {
-	if p.moveCounter < 4 {
+	if p.fixedDepth > 0 {
+		p.maxDepth = p.fixedDepth
+		return
+	}
+	moveCounter := p.pos.MoveNumber()
+	if moveCounter < 4 {
 		p.maxDepth = 8
 	}
-	if p.moveCounter > 3 {
+	if moveCounter > 3 {
 		p.maxDepth = 10
 	}
-	if p.moveCounter > 10 {
+	if moveCounter > 10 {
 		p.maxDepth = 12
 	}
 }
 
 // ChooseMove - choose computer's next move: return x,y coords of move and its score.
 func (p *AlphaBeta) ChooseMove() (xcoord int, ycoord int, value int, leafcount int) {
+	xcoord, ycoord, value, leafcount, _ = p.ChooseMoveContext(context.Background())
+	return
+}
 
-	moves := NewMovekeeper(2*LOSS, p.deterministic)
-
-	p.setDepth()
+// ChooseMoveContext chooses a move the way ChooseMove does, except
+// that if ctx can be done, the search deepens iteratively up to
+// ChooseMove's depth, so there's a move to make when ctx is done.
+// Without a fixed depth, a ctx deadline has it deepen until the
+// deadline instead, the way a time per move does.
+// complete is false if ctx stopped the search before that depth.
+func (p *AlphaBeta) ChooseMoveContext(ctx context.Context) (xcoord int, ycoord int, value int, leafcount int, complete bool) {
+
+	started := time.Now()
+
+	timed := p.moveTime > 0 || p.clock > 0
+	_, hasDeadline := ctx.Deadline()
+
+	p.StopPondering()
+	p.pondered = false
+	var seed *ponderResult
+	if p.ponderHit {
+		p.ponderHit = false
+		r := p.ponderMove
+		if r.complete && !timed && (!hasDeadline || p.fixedDepth > 0) {
+			// Pondering searched this position to ChooseMove's depth
+			p.leafNodeCount, p.nodes = r.leaves, r.nodes
+			p.tableHits, p.tableMisses = r.tableHits, r.tableMisses
+			p.pos.MakeMove(game.Cell(r.x, r.y), MAXIMIZER)
+			p.findPV(game.Cell(r.x, r.y))
+			return r.x, r.y, r.value, r.leaves, true
+		}
+		if r.depth > 0 {
+			// Deepen from where pondering got to, until time
+			// runs out, or to ChooseMove's depth
+			seed = &r
+		}
+	}
 
-	p.leafNodeCount = 0
+	p.idDepth = 0
+	p.leafNodeCount, p.nodes = 0, 0
+	p.tableHits, p.tableMisses = 0, 0
+	if p.table != nil {
+		p.table.newSearch()
+	}
+	p.newOrdering()
+
+	// Only one move of each class of symmetric moves gets
+	// searched. The others have the same value.
+	classes := p.pos.MoveClasses()
+	var order []int
+	for _, class := range classes {
+		order = append(order, class[0])
+	}
+	var values [25]int
+	firstDepth := 1
+	if seed != nil {
+		order = append([]int(nil), seed.order...)
+		values, firstDepth = seed.values, seed.depth+1
+	}
 
-	for i, row := range p.bd {
-		for j, mark := range row {
-			if mark == UNSET {
-				p.bd[i][j] = MAXIMIZER
-				stop, value := p.boardValue(p, 0, i, j, 0)
-				if !stop {
-					value = p.alphaBeta(1, MINIMIZER, 2*LOSS, 2*WIN, i, j, value)
-				}
-				p.bd[i][j] = UNSET
-				moves.SetMove(i, j, value)
+	complete = true
+	if timed || ctx.Done() != nil || p.aspiration > 0 || seed != nil {
+		maxDepth := 25 - p.pos.MoveNumber()
+		if !timed && (!hasDeadline || p.fixedDepth > 0) {
+			p.setDepth()
+			if p.maxDepth < maxDepth {
+				maxDepth = p.maxDepth
 			}
 		}
+		order, complete = p.iterativeDeepening(ctx, order, &values, firstDepth, maxDepth, timed)
+	} else {
+		p.setDepth()
+		p.searchRoot(order, &values, 2*LOSS, 2*WIN)
+	}
+
+	// MoveKeeper chooses randomly among equally valued moves,
+	// symmetric moves included, unless deterministic.
+	moveClass := make(map[int][]int, len(classes))
+	for _, class := range classes {
+		moveClass[class[0]] = class
+	}
+	moves := NewMovekeeper(2*LOSS, p.deterministic)
+	for _, cell := range order {
+		for _, move := range moveClass[cell] {
+			i, j := game.Coords(move)
+			moves.SetMove(i, j, values[cell])
+		}
 	}
 
 	a, b, v := moves.ChooseMove()
 
 	p.MakeMove(a, b, MAXIMIZER)
+	p.findPV(game.Cell(a, b))
+
+	if p.clock > 0 {
+		p.clock -= time.Since(started)
+	}
 
-	return a, b, v, p.leafNodeCount
+	return a, b, v, p.leafNodeCount, complete
 }
 
-// deltaValue calculates the value of the board,
-// including value change from move (x,y).
-func deltaValue(p *AlphaBeta, ply int, x, y int, currentValue int) (stopRecursing bool, value int) {
+// Ponder searches, in the background until StopPondering, the
+// position after the opponent's reply the transposition table
+// has as best. If the opponent makes that reply, and pondering
+// got as deep as ChooseMove would, ChooseMove makes the move
+// pondering found. Under a time budget, or if pondering didn't get
+// that deep, ChooseMove deepens from the deepest depth pondering
+// finished. After any other reply, the next search might find what
+// pondering put in the table useful.
+func (p *AlphaBeta) Ponder() {
+	if p.ponderDone != nil || p.pos.Finished() || p.pos.MoveNumber() > 23 {
+		return
+	}
+	predicted := p.predictedReply()
+	if predicted < 0 {
+		return
+	}
+
+	// A copy of p, with its own board, shares the table
+	w := new(AlphaBeta)
+	*w = *p
+	pos := *p.pos
+	w.pos = &pos
+	w.pos.MakeMove(predicted, MINIMIZER)
+	w.moveTime, w.clock = 0, 0
+	w.ponderHit = false
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	p.ponderCancel, p.ponderDone, p.predicted = cancel, done, predicted
+	go func() {
+		defer close(done)
+		var r ponderResult
+		r.x, r.y, r.value, r.leaves, r.complete = w.ChooseMoveContext(ctx)
+		r.nodes, r.tableHits, r.tableMisses = w.nodes, w.tableHits, w.tableMisses
+		r.depth, r.order, r.values = w.idDepth, w.idOrder, w.idValues
+		p.ponderMove = r
+	}()
+}
+
+// StopPondering stops the search Ponder started, if there is one.
+func (p *AlphaBeta) StopPondering() {
+	if p.ponderDone == nil {
+		return
+	}
+	p.ponderCancel()
+	<-p.ponderDone
+	p.ponderCancel, p.ponderDone = nil, nil
+	p.pondered = true
+}
 
-	relevantQuads := indexedWinningQuads[x][y]
-	for _, quad := range relevantQuads {
-		sum := p.bd[quad[0][0]][quad[0][1]]
-		sum += p.bd[quad[1][0]][quad[1][1]]
-		sum += p.bd[quad[2][0]][quad[2][1]]
-		sum += p.bd[quad[3][0]][quad[3][1]]
+// PonderStats returns how many of the opponent's moves were
+// the reply pondering searched after, and how many weren't.
+func (p *AlphaBeta) PonderStats() (hits, misses int) {
+	return p.ponderHits, p.ponderMisses
+}
+
+// predictedReply returns the opponent's best reply going by the
+// transposition table, -1 if the table doesn't have one.
+func (p *AlphaBeta) predictedReply() int {
+	if p.table == nil {
+		return -1
+	}
+	hash, sym := p.pos.CanonicalHash()
+	entry, ok := p.table.probe(hash)
+	if !ok || entry.move < 0 {
+		return -1
+	}
+	cell := game.Symmetries[game.Inverse(sym)][entry.move]
+	if p.pos.At(cell) != UNSET {
+		return -1
+	}
+	return cell
+}
 
-		if sum == 4 || sum == -4 {
-			return true, p.bd[quad[0][0]][quad[0][1]] * (WIN - ply)
+// findPV records the principal variation, if recording, following
+// the transposition table's best moves from p.pos, after move.
+func (p *AlphaBeta) findPV(move int) {
+	p.pv = nil
+	if !p.recordPV {
+		return
+	}
+	p.pv = []int{move}
+	if p.table == nil {
+		return
+	}
+	pos := *p.pos
+	for !pos.Finished() {
+		hash, sym := pos.CanonicalHash()
+		entry, ok := p.table.probe(hash)
+		if !ok || entry.kind != exactValue || entry.move < 0 {
+			break
 		}
-		if sum == 3 || sum == -3 {
-			value += sum * 10
+		cell := game.Symmetries[game.Inverse(sym)][entry.move]
+		if pos.At(cell) != UNSET {
+			break
 		}
+		pos.Make(cell)
+		p.pv = append(p.pv, cell)
 	}
+}
 
-	relevantTriplets := indexedLosingTriplets[x][y]
-	for _, triplet := range relevantTriplets {
-		sum := p.bd[triplet[0][0]][triplet[0][1]]
-		sum += p.bd[triplet[1][0]][triplet[1][1]]
-		sum += p.bd[triplet[2][0]][triplet[2][1]]
+// searchRoot gives every move in order a search with window alpha,
+// beta. With the full window, MoveKeeper sees the true value of each,
+// and can choose among equals. Values of moves end up in values.
+// Returns false if the search ran out of time before finishing.
+func (p *AlphaBeta) searchRoot(order []int, values *[25]int, alpha, beta int) bool {
+	if p.workers > 1 {
+		return p.searchRootParallel(order, values, alpha, beta)
+	}
+	for _, cell := range order {
+		value := p.rootValue(cell, alpha, beta)
+		if p.stopped {
+			return false
+		}
+		values[cell] = value
+	}
+	return true
+}
 
-		if sum == 3 || sum == -3 {
-			return true, sum / 3 * (LOSS + ply)
+// searchRootParallel does what searchRoot does, with p.workers
+// goroutines. Each has a copy of p, with its own board and counts.
+func (p *AlphaBeta) searchRootParallel(order []int, values *[25]int, alpha, beta int) bool {
+	next := int64(-1)
+	workers := make([]AlphaBeta, p.workers)
+	var wg sync.WaitGroup
+
+	for i := range workers {
+		w := &workers[i]
+		*w = *p
+		pos := *p.pos
+		w.pos = &pos
+		w.leafNodeCount, w.nodes, w.tableHits, w.tableMisses, w.nodeCount = 0, 0, 0, 0, 0
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(order) {
+					return
+				}
+				value := w.rootValue(order[i], alpha, beta)
+				if w.stopped {
+					return
+				}
+				values[order[i]] = value
+			}
+		}()
+	}
+	wg.Wait()
+
+	finished := true
+	for i := range workers {
+		w := &workers[i]
+		p.leafNodeCount += w.leafNodeCount
+		p.nodes += w.nodes
+		p.tableHits += w.tableHits
+		p.tableMisses += w.tableMisses
+		if w.stopped {
+			finished = false
 		}
 	}
+	return finished
+}
 
-	// Give it a slight bias for those early
-	// moves when all losing-triplets and winning-quads
-	// are beyond the horizon.
-	value += p.bd[x][y] * scores[x][y]
+// rootValue gives the root move at cell a search with window alpha,
+// beta, or finds its value with MTD(f), which doesn't need one.
+func (p *AlphaBeta) rootValue(cell int, alpha, beta int) int {
+	p.pos.MakeMove(cell, MAXIMIZER)
+	stop, value := p.staticValue(1, cell, 0)
+	if !stop {
+		if p.mtdf {
+			value = p.mtdfValue(value)
+		} else {
+			value = p.alphaBeta(2, MINIMIZER, alpha, beta, value)
+		}
+	}
+	p.pos.Unmake()
+	return value
+}
+
+// aspirationSearch searches the root moves in order with a window
+// p.aspiration either side of guess, the previous depth's value,
+// then again the moves that need it: those that failed high, with
+// the window open above, so that their values are exact, and all
+// of them, with the full window, if all failed low. Moves that fail
+// low otherwise keep values that are only upper bounds, less than
+// the best move's value. Returns false if out of time.
+func (p *AlphaBeta) aspirationSearch(order []int, values *[25]int, guess int) bool {
+	alpha, beta := guess-p.aspiration, guess+p.aspiration
+	if !p.searchRoot(order, values, alpha, beta) {
+		return false
+	}
+
+	best := 2 * LOSS
+	var high []int
+	for _, cell := range order {
+		if values[cell] > best {
+			best = values[cell]
+		}
+		if values[cell] >= beta {
+			high = append(high, cell)
+		}
+	}
+	if best <= alpha {
+		return p.searchRoot(order, values, 2*LOSS, 2*WIN)
+	}
+	if len(high) > 0 {
+		return p.searchRoot(high, values, alpha, 2*WIN)
+	}
+	return true
+}
+
+// iterativeDeepening searches to depth firstDepth, firstDepth+1...
+// up to maxDepth, until time runs out, ctx is done, or a win or loss
+// is certain. If firstDepth isn't 1, order and values already hold
+// the root moves and their values at the depth before it.
+// Time runs out at the move's budget, if timed, or ctx's deadline,
+// whichever is sooner. Returns the root moves in order of value,
+// with values from the last completed depth, and false if ctx
+// stopped the search. Each depth searches root moves in order of
+// the previous depth's values, and the transposition table holds
+// the previous depth's best replies, so the best line found so far
+// gets searched first.
+func (p *AlphaBeta) iterativeDeepening(ctx context.Context, order []int, values *[25]int, firstDepth, maxDepth int, timed bool) ([]int, bool) {
+	p.deadline = time.Time{}
+	if timed {
+		p.deadline = time.Now().Add(p.moveBudget())
+	}
+	ctxDeadline, hasDeadline := ctx.Deadline()
+	ctxBound := hasDeadline && (p.deadline.IsZero() || ctxDeadline.Before(p.deadline))
+	if ctxBound {
+		p.deadline = ctxDeadline
+	}
+	p.done = ctx.Done()
+	p.nodeCount = 0
+	p.stopped = false
+	defer func() {
+		p.timeLimit = false
+		p.stopped = false
+		p.done = nil
+	}()
+
+	var latest [25]int
+
+	finished := false
+	if firstDepth > 1 {
+		p.idDepth, p.idOrder, p.idValues = firstDepth-1, append([]int(nil), order...), *values
+		if best := values[order[0]]; best > WIN/2 || best < LOSS/2 || firstDepth > maxDepth {
+			finished = true
+		}
+	}
+	for depth := firstDepth; depth <= maxDepth && !finished; depth++ {
+		p.maxDepth = depth
+		// Always finish depth 1, so there's a move to make
+		p.timeLimit = depth > 1
+		var searched bool
+		if p.aspiration > 0 && depth > 1 && !p.mtdf {
+			searched = p.aspirationSearch(order, &latest, values[order[0]])
+		} else {
+			searched = p.searchRoot(order, &latest, 2*LOSS, 2*WIN)
+		}
+		if !searched {
+			break
+		}
+		*values = latest
+		sort.SliceStable(order, func(i, j int) bool {
+			return values[order[i]] > values[order[j]]
+		})
+		p.idDepth, p.idOrder, p.idValues = depth, append([]int(nil), order...), *values
+		if best := values[order[0]]; best > WIN/2 || best < LOSS/2 || depth == maxDepth {
+			// Deeper won't find a faster win, or escape a loss,
+			// or isn't wanted
+			finished = true
+			break
+		}
+		if p.pastDeadline() || p.cancelled() {
+			break
+		}
+	}
+
+	return order, finished || (!ctxBound && ctx.Err() == nil)
+}
+
+// outOfTime checks the clock, and whether ChooseMoveContext's ctx
+// is done, every so often, and then marks the search as stopped.
+func (p *AlphaBeta) outOfTime() bool {
+	if !p.timeLimit {
+		return false
+	}
+	p.nodeCount++
+	if p.nodeCount&1023 == 0 && (p.pastDeadline() || p.cancelled()) {
+		p.stopped = true
+	}
+	return p.stopped
+}
+
+func (p *AlphaBeta) pastDeadline() bool {
+	return !p.deadline.IsZero() && time.Now().After(p.deadline)
+}
+
+// cancelled is true once ChooseMoveContext's ctx is done.
+func (p *AlphaBeta) cancelled() bool {
+	return isDone(p.done)
+}
+
+// staticValue is the value of the move at cell, the ply'th move of
+// the search, by p's Evaluator, and whether the search stops there,
+// because the move won or lost, or at the search's horizon, where
+// the value includes currentValue, the value of the move before.
+func (p *AlphaBeta) staticValue(ply int, cell int, currentValue int) (stopRecursing bool, value int) {
+	final, value := p.eval.Value(p.pos, ply, cell)
+	if final {
+		return true, value
+	}
 
 	// If squava has a "cat game", then this is wrong. Cat
 	// games could stop recursing here.
-	stopRecursing = false
 	if ply >= p.maxDepth {
-		stopRecursing = true
-		value += currentValue
+		return true, value + currentValue
 	}
+	return false, value
+}
 
-	return stopRecursing, value
+// deltaValue calculates the value change from move at cell.
+// It's the basic Evaluator.
+func deltaValue(pos *game.Position, ply int, cell int) (final bool, value int) {
+
+	player := pos.At(cell)
+	mine, theirs := pos.Marks(player), pos.Marks(-player)
+
+	for _, m := range game.QuadMasksAt[cell] {
+		if mine&m == m {
+			return true, player * (WIN - ply)
+		}
+		if theirs&m == 0 && bits.OnesCount32(mine&m) == 3 {
+			value += player * 30
+		}
+	}
+
+	for _, m := range game.TripletMasksAt[cell] {
+		if mine&m == m {
+			return true, player * (LOSS + ply)
+		}
+	}
+
+	// Give it a slight bias for those early
+	// moves when all losing-triplets and winning-quads
+	// are beyond the horizon.
+	value += player * scores[cell]
+
+	return false, value
 }
 
-func (p *AlphaBeta) alphaBeta(ply int, player int, alpha int, beta int, x int, y int, boardValue int) (value int) {
+// alphaBeta finds the minimax value of p.pos, player to move.
+// Moves made at this level are ply plies deep: the root's moves are ply 1.
+// boardValue is the static value of the move that led to p.pos.
+func (p *AlphaBeta) alphaBeta(ply int, player int, alpha int, beta int, boardValue int) (value int) {
 
-	switch player {
-	case MAXIMIZER:
-		value = 2 * LOSS // Possible to score less than LOSS
-		for i, row := range p.bd {
-			for j, marker := range row {
-				if marker == UNSET {
-					p.bd[i][j] = MAXIMIZER
-					stopRecursing, delta := p.boardValue(p, ply, x, y, boardValue)
-					if stopRecursing {
-						p.bd[i][j] = UNSET
-						p.leafNodeCount++
-						return delta
-					}
-					n := p.alphaBeta(ply+1, MINIMIZER, alpha, beta, i, j, delta)
-					p.bd[i][j] = UNSET
-					if n > value {
-						value = n
-					}
-					if value > alpha {
-						alpha = value
+	if p.outOfTime() {
+		return 0
+	}
+	p.nodes++
+
+	empty := p.pos.Empty()
+	if empty == 0 {
+		// Cat got the game
+		p.leafNodeCount++
+		return boardValue
+	}
+
+	if p.tablebase != nil && bits.OnesCount32(empty) <= p.tablebase.MaxEmpty() {
+		if v, ok := p.tablebase.Probe(p.pos); ok {
+			p.leafNodeCount++
+			return tablebaseValue(v, ply, player)
+		}
+	}
+
+	depth := p.maxDepth - ply + 1
+	// All symmetric positions share a table entry. The entry's
+	// best move is for the position symmetry sym turns p.pos into.
+	hash, sym := p.pos.CanonicalHash()
+	alphaOrig, betaOrig := alpha, beta
+
+	// The table's best move for this position gets searched first
+	first := -1
+	if p.table != nil {
+		if entry, ok := p.table.probe(hash); ok {
+			p.tableHits++
+			if entry.move >= 0 {
+				first = game.Symmetries[game.Inverse(sym)][entry.move]
+			}
+			if int(entry.depth) >= depth && (!p.deterministic || p.workers <= 1) {
+				v := valueFromTable(int(entry.value), ply)
+				switch entry.kind {
+				case exactValue:
+					return v
+				case lowerBound:
+					if v > alpha {
+						alpha = v
 					}
-					if beta <= alpha {
-						return value
+				case upperBound:
+					if v < beta {
+						beta = v
 					}
 				}
+				if beta <= alpha {
+					return v
+				}
 			}
+		} else {
+			p.tableMisses++
 		}
-	case MINIMIZER:
-		value = 2 * WIN // You can score greater than WIN
-		for i, row := range p.bd {
-			for j, marker := range row {
-				if marker == UNSET {
-					p.bd[i][j] = player
-					stopRecursing, delta := p.boardValue(p, ply, x, y, boardValue)
-					if stopRecursing {
-						p.bd[i][j] = UNSET
-						p.leafNodeCount++
-						return delta
-					}
-					n := p.alphaBeta(ply+1, -player, alpha, beta, i, j, delta)
-					p.bd[i][j] = UNSET
-					if n < value {
-						value = n
-					}
-					if value < beta {
-						beta = value
-					}
-					if beta <= alpha {
-						return value
-					}
-				}
+	}
+
+	value = 2 * LOSS * player // Possible to score less than LOSS, or more than WIN
+	best := -1
+
+	var moves [25]int
+	count := p.orderMoves(ply, player, empty, first, &moves)
+	for i, cell := range moves[:count] {
+
+		p.pos.MakeMove(cell, player)
+		stopRecursing, n := p.staticValue(ply, cell, boardValue)
+		if stopRecursing {
+			p.leafNodeCount++
+		} else if p.pvs && i > 0 {
+			n = p.scout(ply, player, alpha, beta, n)
+		} else {
+			n = p.alphaBeta(ply+1, -player, alpha, beta, n)
+		}
+		p.pos.Unmake()
+		if p.stopped {
+			// Value of an abandoned search isn't worth keeping
+			return 0
+		}
+
+		switch player {
+		case MAXIMIZER:
+			if n > value {
+				value = n
+				best = cell
+			}
+			if value > alpha {
+				alpha = value
 			}
+		case MINIMIZER:
+			if n < value {
+				value = n
+				best = cell
+			}
+			if value < beta {
+				beta = value
+			}
+		}
+		if beta <= alpha {
+			p.rememberCutoff(ply, player, cell, depth)
+			break
 		}
 	}
 
+	if p.table != nil {
+		var kind uint8 = exactValue
+		switch {
+		case value <= alphaOrig:
+			kind = upperBound
+		case value >= betaOrig:
+			kind = lowerBound
+		}
+		if best >= 0 {
+			best = game.Symmetries[sym][best]
+		}
+		p.table.store(hash, depth, kind, valueToTable(value, ply), best)
+	}
+
 	return value
 }
 
-// PrintBoard prints the board in a human-readable fashion.
-// Necessary to encapsulate the internal representation of
-// a 5x5 board
+// scout searches the position after player's move at ply, not
+// the first move searched, with a null window, to see if it's any
+// better for player than the best so far, and only if it is, with
+// the window alpha, beta, to find its value.
+func (p *AlphaBeta) scout(ply int, player int, alpha int, beta int, boardValue int) int {
+	if player == MAXIMIZER {
+		n := p.alphaBeta(ply+1, MINIMIZER, alpha, alpha+1, boardValue)
+		if n > alpha && n < beta && !p.stopped {
+			n = p.alphaBeta(ply+1, MINIMIZER, alpha, beta, boardValue)
+		}
+		return n
+	}
+	n := p.alphaBeta(ply+1, MAXIMIZER, beta-1, beta, boardValue)
+	if n < beta && n > alpha && !p.stopped {
+		n = p.alphaBeta(ply+1, MAXIMIZER, alpha, beta, boardValue)
+	}
+	return n
+}
+
+// tablebaseValue turns the tablebase value of a position into the
+// value alphaBeta would find, player to move making the ply'th move.
+// A win or loss in d moves comes d-1 plies later.
+func tablebaseValue(v solution.Value, ply int, player int) int {
+	last := ply + v.Distance() - 1
+	switch v.Result() {
+	case solution.Win:
+		return player * (WIN - last)
+	case solution.Loss:
+		return player * (LOSS + last)
+	}
+	return 0
+}
+
+// String returns the board in a human-readable fashion.
 func (p *AlphaBeta) String() string {
-	bd := new(strings.Builder)
-	fmt.Fprintf(bd, "   0 1 2 3 4\n")
-	for i, row := range p.bd {
-		fmt.Fprintf(bd, "%d  ", i)
-		for _, v := range row {
-			var marker string
-			switch v {
-			case MAXIMIZER:
-				marker = "X"
-			case MINIMIZER:
-				marker = "O"
-			case UNSET:
-				marker = "_"
-			}
-			fmt.Fprintf(bd, "%s ", marker)
-		}
-		fmt.Fprintf(bd, "\n")
-	}
-	fmt.Fprintf(bd, "\n")
-	return bd.String()
-}
-
-var losingTriplets = [][][]int{
-	{{0, 0}, {1, 0}, {2, 0}},
-	{{0, 0}, {0, 1}, {0, 2}},
-	{{0, 0}, {1, 1}, {2, 2}},
-	{{1, 0}, {2, 0}, {3, 0}},
-	{{1, 0}, {1, 1}, {1, 2}},
-	{{1, 0}, {2, 1}, {3, 2}},
-	{{2, 0}, {3, 0}, {4, 0}},
-	{{2, 0}, {2, 1}, {2, 2}},
-	{{2, 0}, {1, 1}, {0, 2}},
-	{{2, 0}, {3, 1}, {4, 2}},
-	{{3, 0}, {3, 1}, {3, 2}},
-	{{3, 0}, {2, 1}, {1, 2}},
-	{{4, 0}, {4, 1}, {4, 2}},
-	{{4, 0}, {3, 1}, {2, 2}},
-	{{0, 1}, {1, 1}, {2, 1}},
-	{{0, 1}, {0, 2}, {0, 3}},
-	{{0, 1}, {1, 2}, {2, 3}},
-	{{1, 1}, {2, 1}, {3, 1}},
-	{{1, 1}, {1, 2}, {1, 3}},
-	{{1, 1}, {2, 2}, {3, 3}},
-	{{2, 1}, {3, 1}, {4, 1}},
-	{{2, 1}, {2, 2}, {2, 3}},
-	{{2, 1}, {1, 2}, {0, 3}},
-	{{2, 1}, {3, 2}, {4, 3}},
-	{{3, 1}, {3, 2}, {3, 3}},
-	{{3, 1}, {2, 2}, {1, 3}},
-	{{4, 1}, {4, 2}, {4, 3}},
-	{{4, 1}, {3, 2}, {2, 3}},
-	{{0, 2}, {1, 2}, {2, 2}},
-	{{0, 2}, {0, 3}, {0, 4}},
-	{{0, 2}, {1, 3}, {2, 4}},
-	{{1, 2}, {2, 2}, {3, 2}},
-	{{1, 2}, {1, 3}, {1, 4}},
-	{{1, 2}, {2, 3}, {3, 4}},
-	{{2, 2}, {3, 2}, {4, 2}},
-	{{2, 2}, {2, 3}, {2, 4}},
-	{{2, 2}, {1, 3}, {0, 4}},
-	{{2, 2}, {3, 3}, {4, 4}},
-	{{3, 2}, {3, 3}, {3, 4}},
-	{{3, 2}, {2, 3}, {1, 4}},
-	{{4, 2}, {4, 3}, {4, 4}},
-	{{4, 2}, {3, 3}, {2, 4}},
-	{{0, 3}, {1, 3}, {2, 3}},
-	{{1, 3}, {2, 3}, {3, 3}},
-	{{2, 3}, {3, 3}, {4, 3}},
-	{{0, 4}, {1, 4}, {2, 4}},
-	{{1, 4}, {2, 4}, {3, 4}},
-	{{2, 4}, {3, 4}, {4, 4}},
-}
-var winningQuads = [][][]int{
-	{{0, 0}, {1, 0}, {2, 0}, {3, 0}},
-	{{0, 0}, {0, 1}, {0, 2}, {0, 3}},
-	{{0, 0}, {1, 1}, {2, 2}, {3, 3}},
-	{{0, 1}, {1, 1}, {2, 1}, {3, 1}},
-	{{0, 1}, {0, 2}, {0, 3}, {0, 4}},
-	{{0, 1}, {1, 2}, {2, 3}, {3, 4}},
-	{{0, 2}, {1, 2}, {2, 2}, {3, 2}},
-	{{0, 3}, {1, 3}, {2, 3}, {3, 3}},
-	{{0, 4}, {1, 4}, {2, 4}, {3, 4}},
-	{{1, 0}, {2, 0}, {3, 0}, {4, 0}},
-	{{1, 0}, {1, 1}, {1, 2}, {1, 3}},
-	{{1, 0}, {2, 1}, {3, 2}, {4, 3}},
-	{{1, 1}, {2, 1}, {3, 1}, {4, 1}},
-	{{1, 1}, {1, 2}, {1, 3}, {1, 4}},
-	{{1, 1}, {2, 2}, {3, 3}, {4, 4}},
-	{{1, 2}, {2, 2}, {3, 2}, {4, 2}},
-	{{1, 3}, {2, 3}, {3, 3}, {4, 3}},
-	{{1, 4}, {2, 4}, {3, 4}, {4, 4}},
-	{{2, 0}, {2, 1}, {2, 2}, {2, 3}},
-	{{2, 1}, {2, 2}, {2, 3}, {2, 4}},
-	{{3, 0}, {3, 1}, {3, 2}, {3, 3}},
-	{{3, 0}, {2, 1}, {1, 2}, {0, 3}},
-	{{3, 1}, {3, 2}, {3, 3}, {3, 4}},
-	{{3, 1}, {2, 2}, {1, 3}, {0, 4}},
-	{{4, 0}, {4, 1}, {4, 2}, {4, 3}},
-	{{4, 0}, {3, 1}, {2, 2}, {1, 3}},
-	{{4, 1}, {4, 2}, {4, 3}, {4, 4}},
-	{{4, 1}, {3, 2}, {2, 3}, {1, 4}},
-}
-
-var scores [5][5]int
+	return p.pos.String()
+}
+
+var scores [25]int
 
 // SetScores does any prep on a new board, like
 // initializing a small bias on each cell
 func (p *AlphaBeta) SetScores(randomize bool) {
 	if randomize {
 		var vals = [11]int{-5, -4, -3 - 2, -1, 0, 1, 2, 3, 4, 5}
-		for i, row := range scores {
-			for j := range row {
-				scores[i][j] = vals[rand.Intn(11)]
-			}
+		for cell := range scores {
+			scores[cell] = vals[rand.Intn(11)]
 		}
 	} else {
-		scores = [5][5]int{
-			{3, 3, 0, 3, 3},
-			{3, 4, 1, 4, 3},
-			{0, 1, 0, 1, 0},
-			{3, 4, 1, 4, 3},
-			{3, 3, 0, 3, 3},
+		scores = [25]int{
+			3, 3, 0, 3, 3,
+			3, 4, 1, 4, 3,
+			0, 1, 0, 1, 0,
+			3, 4, 1, 4, 3,
+			3, 3, 0, 3, 3,
 		}
 	}
 }
@@ -355,123 +910,98 @@ func (p *AlphaBeta) SetScores(randomize bool) {
 // FindWinner returns the winner of the current game,
 // if any, based on internal board representation
 func (p *AlphaBeta) FindWinner() int {
-	for _, quad := range winningQuads {
-		sum := p.bd[quad[0][0]][quad[0][1]]
-		sum += p.bd[quad[1][0]][quad[1][1]]
-		sum += p.bd[quad[2][0]][quad[2][1]]
-		sum += p.bd[quad[3][0]][quad[3][1]]
+	return p.pos.Outcome()
+}
 
-		if sum == 4 || sum == -4 {
-			return p.bd[quad[0][0]][quad[0][1]]
+// Calculates and returns the value of the move at cell
+// Only considers value gained or lost from the cell.
+// It's the avoid Evaluator.
+func deltaValue2(pos *game.Position, ply int, cell int) (final bool, value int) {
+
+	player := pos.At(cell)
+	mine := pos.Marks(player)
+
+	for _, m := range game.QuadMasksAt[cell] {
+		if mine&m == m {
+			return true, player * (WIN - ply)
 		}
 	}
 
-	for _, triplet := range losingTriplets {
-		sum := p.bd[triplet[0][0]][triplet[0][1]]
-		sum += p.bd[triplet[1][0]][triplet[1][1]]
-		sum += p.bd[triplet[2][0]][triplet[2][1]]
-
-		if sum == 3 || sum == -3 {
-			return -p.bd[triplet[0][0]][triplet[0][1]]
+	for _, m := range game.TripletMasksAt[cell] {
+		if mine&m == m {
+			return true, player * (LOSS + ply)
 		}
 	}
 
-	return 0 // Cat got the game
+	return false, avoidValue(pos, cell)
 }
 
-// Calculates and returns the value of the move (x,y)
-// Only considers value gained or lost from the cell (x,y)
-func deltaValue2(p *AlphaBeta, ply int, x, y int, currentValue int) (stopRecursing bool, value int) {
+// avoidValue is deltaValue2's value of the move at cell, for a move
+// that neither wins nor loses: points for 3 of an open 4 in a row,
+// points off for getting into positions that are bad to be in.
+func avoidValue(pos *game.Position, cell int) (value int) {
 
-	relevantQuads := indexedWinningQuads[x][y]
-	for _, quad := range relevantQuads {
-		sum := p.bd[quad[0][0]][quad[0][1]]
-		sum += p.bd[quad[1][0]][quad[1][1]]
-		sum += p.bd[quad[2][0]][quad[2][1]]
-		sum += p.bd[quad[3][0]][quad[3][1]]
+	player := pos.At(cell)
+	mine, theirs := pos.Marks(player), pos.Marks(-player)
 
-		if sum == 4 || sum == -4 {
-			return true, p.bd[quad[0][0]][quad[0][1]] * (WIN - ply)
-		}
-		if sum == 3 || sum == -3 {
-			value += sum * 10
+	for _, m := range game.QuadMasksAt[cell] {
+		if theirs&m == 0 && bits.OnesCount32(mine&m) == 3 {
+			value += player * 30
 		}
 	}
 
-	relevantTriplets := indexedLosingTriplets[x][y]
-	for _, triplet := range relevantTriplets {
-		sum := p.bd[triplet[0][0]][triplet[0][1]]
-		sum += p.bd[triplet[1][0]][triplet[1][1]]
-		sum += p.bd[triplet[2][0]][triplet[2][1]]
-
-		if sum == 3 || sum == -3 {
-			return true, sum / 3 * (LOSS + ply)
-		}
-	}
+	bit := uint32(1) << cell
 
-	for _, triplet := range no2 {
-		for _, pair := range triplet {
-			if x == pair[0] && y == pair[1] {
-				sum := p.bd[triplet[0][0]][triplet[0][1]]
-				sum += p.bd[triplet[1][0]][triplet[1][1]]
-				sum += p.bd[triplet[2][0]][triplet[2][1]]
-				if sum == 2 || sum == -2 {
-					value += p.bd[x][y] * -100
-				}
-				break
-			}
+	for _, m := range no2 {
+		if m&bit != 0 && theirs&m == 0 && bits.OnesCount32(mine&m) == 2 {
+			value += player * -100
 		}
 	}
 
 	for _, quad := range noMiddle2 {
-		player := p.bd[x][y]
-		if (x == quad[1][0] && y == quad[1][1] && player == p.bd[quad[2][0]][quad[2][1]]) ||
-			(x == quad[2][0] && y == quad[2][1] && player == p.bd[quad[1][0]][quad[1][1]]) {
-
-			sum := p.bd[quad[0][0]][quad[0][1]]
-			sum += p.bd[quad[1][0]][quad[1][1]]
-			sum += p.bd[quad[2][0]][quad[2][1]]
-			sum += p.bd[quad[3][0]][quad[3][1]]
-
-			if sum == 2 || sum == -2 {
-				value += player * -100
-			}
+		if quad.middle&bit != 0 && mine&quad.middle == quad.middle &&
+			bits.OnesCount32(mine&quad.ends) == bits.OnesCount32(theirs&quad.ends) {
+			value += player * -100
 		}
 	}
 
 	// Give it a slight bias for those early
 	// moves when all losing-triplets and winning-quads
 	// are beyond the horizon.
-	value += p.bd[x][y] * scores[x][y]
-
-	// If squava has a "cat game", then this is wrong. Cat
-	// games could stop recursing here.
-	stopRecursing = false
-	if ply >= p.maxDepth {
-		stopRecursing = true
-		value += currentValue
-	}
+	value += player * scores[cell]
 
-	return stopRecursing, value
+	return value
 }
 
-// 4-in-a-row where you don't want to have the middle 2
-var noMiddle2 = [4][4][2]int{
-	{{3, 0}, {2, 1}, {1, 2}, {0, 3}},
-	{{1, 0}, {2, 1}, {3, 2}, {4, 3}},
-	{{0, 1}, {1, 2}, {2, 3}, {3, 4}},
-	{{1, 4}, {2, 3}, {3, 2}, {4, 1}},
+// 4-in-a-row where you don't want to have the middle 2:
+// cells 15, 11, 7, 3 and so on.
+var noMiddle2 = [4]struct{ middle, ends uint32 }{
+	{middle: 1<<11 | 1<<7, ends: 1<<15 | 1<<3},
+	{middle: 1<<11 | 1<<17, ends: 1<<5 | 1<<23},
+	{middle: 1<<7 | 1<<13, ends: 1<<1 | 1<<19},
+	{middle: 1<<13 | 1<<17, ends: 1<<9 | 1<<21},
 }
 
-// 3-in-a-row where you don't want any 2 plus a blank
-var no2 = [4][3][2]int{
-	{{2, 0}, {1, 1}, {0, 2}},
-	{{0, 2}, {1, 3}, {2, 4}},
-	{{4, 2}, {3, 3}, {2, 4}},
-	{{4, 2}, {3, 1}, {2, 0}},
+// 3-in-a-row where you don't want any 2 plus a blank:
+// cells 10, 6, 2 and so on.
+var no2 = [4]uint32{
+	1<<10 | 1<<6 | 1<<2,
+	1<<2 | 1<<8 | 1<<14,
+	1<<22 | 1<<18 | 1<<14,
+	1<<22 | 1<<16 | 1<<10,
 }
 
+// SetAvoid has p value positions with deltaValue2, the avoid Evaluator.
 func (p *AlphaBeta) SetAvoid() {
-	p.name = "A/B+Avoid"
-	p.boardValue = deltaValue2
+	p.SetEvaluator("avoid", EvaluatorFunc(deltaValue2))
+}
+
+// SetEvaluator has p value positions at its search horizon with
+// eval, which name, the name it's registered by, goes into p's Name.
+func (p *AlphaBeta) SetEvaluator(name string, eval Evaluator) {
+	p.eval = eval
+	p.name = "AlphaBeta"
+	if name != "basic" {
+		p.name = "A/B+" + strings.ToUpper(name[:1]) + name[1:]
+	}
 }
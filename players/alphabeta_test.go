@@ -0,0 +1,89 @@
+package players
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"squava2/game"
+)
+
+// TestDeterministicWorkers checks that a deterministic player
+// chooses the same move, of the same value, with any number
+// of workers searching root moves.
+func TestDeterministicWorkers(t *testing.T) {
+	want := searchBench(t, "ab:depth=6,det")
+	for _, workers := range []int{1, 2, 4} {
+		spec := fmt.Sprintf("ab:depth=6,det,threads=%d", workers)
+		if got := searchBench(t, spec); got != want {
+			t.Errorf("%s chose <%d,%d> worth %d, want <%d,%d> worth %d",
+				spec, got.x, got.y, got.value, want.x, want.y, want.value)
+		}
+	}
+}
+
+type benchChoice struct {
+	x, y, value int
+}
+
+// searchBench has the player spec describes choose a move in benchPosition.
+func searchBench(t *testing.T, spec string) benchChoice {
+	t.Helper()
+	player, err := NewPlayer(spec, Env{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	player.SetPosition(benchPosition(), MAXIMIZER)
+	x, y, value, _ := player.ChooseMove()
+	if cell := game.Cell(x, y); benchPosition().At(cell) != UNSET {
+		t.Fatalf("%s chose <%d,%d>, not an empty cell", spec, x, y)
+	}
+	return benchChoice{x, y, value}
+}
+
+// TestSearchesAgree checks that PVS, aspiration windows and MTD(f)
+// find the same root value as plain alpha/beta, at the same depth. They
+// can choose different moves of that value: aspiration windows
+// search root moves in a different order. Evaluators other than A's
+// basic give a position reached by moves in different orders
+// different values, so with a transposition table, their values
+// depend on search order too.
+func TestSearchesAgree(t *testing.T) {
+	for _, depth := range []int{4, 6} {
+		want := searchBench(t, fmt.Sprintf("A:depth=%d,det", depth)).value
+		for _, options := range []string{"pvs", "aspiration=30", "pvs,aspiration=30", "search=mtdf"} {
+			spec := fmt.Sprintf("A:depth=%d,det,%s", depth, options)
+			if got := searchBench(t, spec).value; got != want {
+				t.Errorf("%s found root value %d, A found %d", spec, got, want)
+			}
+		}
+	}
+}
+
+// TestPonderHitDeepens checks that under a time budget, a ponder
+// hit deepens from the depth pondering finished, even with no time
+// to finish a depth of its own.
+func TestPonderHitDeepens(t *testing.T) {
+	p := NewAlphaBeta(false, 10)
+	p.SetPosition(game.NewPosition(MAXIMIZER), MAXIMIZER)
+	p.SetMoveTime(100 * time.Millisecond)
+	p.ChooseMove()
+
+	p.Ponder()
+	if p.ponderDone == nil {
+		t.Fatal("no reply to ponder")
+	}
+	time.Sleep(200 * time.Millisecond)
+	x, y := game.Coords(p.predicted)
+	p.MakeMove(x, y, MINIMIZER)
+	pondered := p.ponderMove.depth
+	if hits, _ := p.PonderStats(); hits != 1 || pondered < 2 {
+		t.Fatalf("%d ponder hits, pondering finished depth %d", hits, pondered)
+	}
+
+	p.SetMoveTime(time.Nanosecond)
+	p.ChooseMove()
+	if p.idDepth < pondered {
+		t.Errorf("searched to depth %d after a ponder hit, pondering got to %d", p.idDepth, pondered)
+	}
+}
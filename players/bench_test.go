@@ -0,0 +1,172 @@
+package players
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"squava2/game"
+)
+
+/*
+ * Benchmarks on a fixed position, 10 moves into a game, so that
+ * changes to the board representation, static evaluation, or
+ * searches can be compared before and after:
+ *
+ *   go test -run XXX -bench . ./players
+ *   go test -run XXX -bench 'ChooseMove/A$' ./players
+ *
+ * MCTS players spend most of their time in playouts, categorizing
+ * moves as wins, losses or others. Alpha-beta players spend most of
+ * their time in static valuation of leaf nodes. BenchmarkChooseMove
+ * times a whole ChooseMove of each of some player specs, and reports
+ * leaves, and alpha-beta nodes, per ChooseMove: node counts show how
+ * well alpha-beta players order moves, compare A with A:order=plain.
+ * BenchmarkMCTSWorkers shows how iterations per second scale with
+ * MCTS/UCB1 workers, root and tree parallel. That needs as many CPUs
+ * as workers.
+ */
+
+var benchMoves = [][2]int{{2, 0}, {2, 2}, {0, 0}, {3, 0}, {0, 1}, {0, 3}, {3, 4}, {1, 2}, {2, 1}, {3, 1}}
+
+// benchSpecs are the players BenchmarkChooseMove times
+var benchSpecs = []string{
+	"A", "G", "M", "U", "R",
+	"A:order=plain", "A:pvs", "A:aspiration=30", "A:pvs,aspiration=30", "ab:search=mtdf",
+}
+
+// benchIterations is how many iterations MCTS players search
+const benchIterations = 20000
+
+// benchPosition returns the position after benchMoves,
+// the way the player to move sees it.
+func benchPosition() *game.Position {
+	var history []int
+	for _, m := range benchMoves {
+		history = append(history, game.Cell(m[0], m[1]))
+	}
+	return game.MoverView(history)
+}
+
+func BenchmarkCategorizeMoves(b *testing.B) {
+	pos := benchPosition()
+	moves := pos.EmptyCells()
+	for n := 0; n < b.N; n++ {
+		categorizeMoves(pos, moves, pos.ToMove())
+	}
+}
+
+// BenchmarkPlayout times heavy playouts, which categorize
+// moves to make winning moves and avoid losing ones.
+func BenchmarkPlayout(b *testing.B) {
+	p := NewMCTS(benchIterations)
+	pos := benchPosition()
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < b.N; n++ {
+		state := *pos
+		p.playout(&state, rng)
+	}
+}
+
+// BenchmarkDeltaValue times the basic evaluation of one leaf.
+func BenchmarkDeltaValue(b *testing.B) {
+	benchmarkEvaluator(b, EvaluatorFunc(deltaValue))
+}
+
+// BenchmarkDeltaValue2 times the avoid evaluation of one leaf.
+func BenchmarkDeltaValue2(b *testing.B) {
+	benchmarkEvaluator(b, EvaluatorFunc(deltaValue2))
+}
+
+// BenchmarkEvaluators times each registered Evaluator's evaluation of one leaf.
+func BenchmarkEvaluators(b *testing.B) {
+	for _, name := range EvaluatorNames() {
+		eval, err := NewEvaluator(name, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			benchmarkEvaluator(b, eval)
+		})
+	}
+}
+
+// benchmarkEvaluator times eval's evaluation of one leaf, the
+// position after a move at each empty cell in turn. The leaves
+// get made before the timing starts.
+func benchmarkEvaluator(b *testing.B, eval Evaluator) {
+	var leaves []*game.Position
+	cells := benchPosition().EmptyCells()
+	for _, cell := range cells {
+		leaf := benchPosition()
+		leaf.Make(cell)
+		leaves = append(leaves, leaf)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		i := n % len(cells)
+		eval.Value(leaves[i], 1, cells[i])
+	}
+}
+
+func BenchmarkChooseMove(b *testing.B) {
+	env := Env{
+		Deterministic: true,
+		Defaults:      fmt.Sprintf("iters=%d", benchIterations),
+	}
+	for _, spec := range benchSpecs {
+		b.Run(spec, func(b *testing.B) {
+			var leaves, nodes int
+			var searching time.Duration
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				player := benchPlayer(b, spec, env)
+				b.StartTimer()
+				start := time.Now()
+				_, _, _, leafCount := player.ChooseMove()
+				searching += time.Since(start)
+				leaves += leafCount
+				if ab, ok := player.(*AlphaBeta); ok {
+					nodes += ab.Nodes()
+				}
+			}
+			b.ReportMetric(float64(leaves)/float64(b.N), "leaves/op")
+			b.ReportMetric(float64(searching.Nanoseconds())/float64(leaves), "ns/leaf")
+			if nodes > 0 {
+				b.ReportMetric(float64(nodes)/float64(b.N), "nodes/op")
+			}
+		})
+	}
+}
+
+func BenchmarkMCTSWorkers(b *testing.B) {
+	env := Env{Defaults: fmt.Sprintf("iters=%d", benchIterations)}
+	for _, mode := range []string{"root", "tree"} {
+		for _, workers := range []int{1, 2, 4, 8} {
+			spec := fmt.Sprintf("mcts:ucb1,threads=%d,parallel=%s", workers, mode)
+			b.Run(fmt.Sprintf("%s/%d", mode, workers), func(b *testing.B) {
+				var searching time.Duration
+				for n := 0; n < b.N; n++ {
+					b.StopTimer()
+					player := benchPlayer(b, spec, env)
+					b.StartTimer()
+					start := time.Now()
+					player.ChooseMove()
+					searching += time.Since(start)
+				}
+				b.ReportMetric(float64(benchIterations*b.N)/searching.Seconds(), "iterations/sec")
+			})
+		}
+	}
+}
+
+// benchPlayer makes the player spec describes, in benchPosition.
+func benchPlayer(b *testing.B, spec string, env Env) Player {
+	player, err := NewPlayer(spec, env)
+	if err != nil {
+		b.Fatal(err)
+	}
+	player.SetPosition(benchPosition(), MAXIMIZER)
+	return player
+}
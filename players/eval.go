@@ -0,0 +1,131 @@
+package players
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"squava2/game"
+)
+
+/*
+ * Static evaluation by name. Alpha/beta players value the positions
+ * at their search horizon with an Evaluator, and MCTS players with
+ * evaluator playouts choose between moves with one. Player specs
+ * pick one with eval=NAME:
+ *
+ *   ab:eval=block,depth=8
+ *   mcts:ucb1,playout=evaluator,eval=basic
+ *
+ * Other packages can register Evaluators of their own, to try them
+ * out without changing this one.
+ */
+
+// An Evaluator gives the move at cell, the ply'th move of a search,
+// just made in pos, a static value, positive if it's good for
+// MAXIMIZER, negative if good for MINIMIZER, whoever made it.
+// A move that wins or loses is final, and worth WIN - ply or
+// LOSS + ply to whoever made it, so that quicker wins and slower
+// losses are better. Any other move's value is the change it makes
+// to the value of the position before it.
+type Evaluator interface {
+	Value(pos *game.Position, ply int, cell int) (final bool, value int)
+}
+
+// EvaluatorFunc is a function that's an Evaluator.
+type EvaluatorFunc func(pos *game.Position, ply int, cell int) (final bool, value int)
+
+// Value calls f.
+func (f EvaluatorFunc) Value(pos *game.Position, ply int, cell int) (bool, int) {
+	return f(pos, ply, cell)
+}
+
+// EvaluatorFactory makes an Evaluator, with options
+// from the spec of the player that values positions with it.
+type EvaluatorFactory func(opts *Options) (Evaluator, error)
+
+type evaluatorRegistration struct {
+	factory EvaluatorFactory
+	usage   string
+}
+
+var evaluators = map[string]evaluatorRegistration{}
+
+// RegisterEvaluator makes the Evaluators factory makes
+// available to player specs as eval=name.
+func RegisterEvaluator(name string, usage string, factory EvaluatorFactory) {
+	evaluators[name] = evaluatorRegistration{factory: factory, usage: usage}
+}
+
+// evaluatorOf is a factory for an Evaluator that takes no options.
+func evaluatorOf(eval Evaluator) EvaluatorFactory {
+	return func(*Options) (Evaluator, error) {
+		return eval, nil
+	}
+}
+
+func init() {
+	RegisterEvaluator("basic", "points for 3 of an open 4 in a row", evaluatorOf(EvaluatorFunc(deltaValue)))
+	RegisterEvaluator("avoid", "basic, and points off for 2 of some 3 and 4 in a rows that often lose", evaluatorOf(EvaluatorFunc(deltaValue2)))
+	RegisterEvaluator("block", "avoid, and points for blocking the opponent's 3 of an open 4 in a row", evaluatorOf(EvaluatorFunc(blockValue)))
+	RegisterEvaluator("weighted", "features of the move, weighted, weights=FILE of JSON weights, avoid's if none", newWeightedEvaluator)
+}
+
+// NewEvaluator makes the Evaluator registered as name,
+// with options opts, which can be nil.
+func NewEvaluator(name string, opts *Options) (Evaluator, error) {
+	reg, ok := evaluators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown evaluator %s", name)
+	}
+	if opts == nil {
+		opts = &Options{values: map[string]string{}, used: map[string]bool{}}
+	}
+	eval, err := reg.factory(opts)
+	if err == nil {
+		err = opts.err
+	}
+	return eval, err
+}
+
+// EvaluatorNames returns the names of the registered Evaluators, sorted.
+func EvaluatorNames() []string {
+	var names []string
+	for name := range evaluators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// evaluatorFromOptions makes the Evaluator option eval names,
+// defaultEval if none, returning its name too.
+func evaluatorFromOptions(opts *Options, defaultEval string) (string, Evaluator, error) {
+	name := opts.Choice("eval", defaultEval, EvaluatorNames()...)
+	if opts.err != nil {
+		return "", nil, opts.err
+	}
+	eval, err := NewEvaluator(name, opts)
+	return name, eval, err
+}
+
+// blockValue is deltaValue2's value of the move at cell, plus points
+// for each open 4 in a row of the opponent's that it blocks.
+func blockValue(pos *game.Position, ply int, cell int) (final bool, value int) {
+	final, value = deltaValue2(pos, ply, cell)
+	if final {
+		return final, value
+	}
+
+	player := pos.At(cell)
+	mine, theirs := pos.Marks(player), pos.Marks(-player)
+	bit := uint32(1) << cell
+
+	for _, m := range game.QuadMasksAt[cell] {
+		if mine&m == bit && bits.OnesCount32(theirs&m) == 3 {
+			value += player * 25
+		}
+	}
+
+	return false, value
+}
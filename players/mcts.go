@@ -1,10 +1,15 @@
 package players
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"math/rand"
-	"strings"
+	"sync"
+	"sync/atomic"
+
+	"squava2/game"
+	"squava2/solution"
 )
 
 /*
@@ -13,101 +18,439 @@ import (
  * from: https://en.wikipedia.org/wiki/Monte_Carlo_tree_search#cite_note-37
  */
 
-type gameState struct {
-	player int
-	board  [25]int
-}
-
 type Node struct {
 	move         int
 	player       int
 	parent       *Node
 	childNodes   []*Node
-	wins         int
-	visits       int
+	wins         int64
+	visits       int64
 	untriedMoves []int
+	proven       int32 // provenWin or provenLoss for player, 0 if not proven
+
+	// All-moves-as-first: playouts through the parent in
+	// which player marked move at any point, and player won
+	amafWins   int64
+	amafVisits int64
+
+	// When workers share a tree, mu guards childNodes and
+	// untriedMoves, and wins, visits and proven change atomically.
+	mu sync.Mutex
 }
 
+// MCTS-Solver: a node whose move ends the game, or whose
+// opponent has a winning reply, or all of whose opponent's replies
+// lose, is a proven win or loss for the player who made the move.
+// From: Winands, Bjornsson, Saito, "Monte-Carlo Tree Search Solver"
+const (
+	provenWin  = 1
+	provenLoss = -1
+)
+
 type MCTS struct {
 	name       string
-	board      [25]int
+	pos        game.Position
 	iterations int
 	scoreFn    func(*Node) float64
+	tablebase  *solution.Tablebase
+	roots      []*Node // trees kept from the previous move, if any
+	inherited  int     // visits roots had before the latest search
+	workers    int
+	parallel   int  // RootParallel or TreeParallel
+	rave       bool // keep all-moves-as-first counts
+
+	exploration    float64   // UCB1 exploration constant
+	playoutPolicy  int       // HeavyPlayout, LightPlayout or EvaluatorPlayout
+	eval           Evaluator // EvaluatorPlayout's
+	finalSelection int       // MostVisits, BestRatio or RobustMax
+
+	done    <-chan struct{} // ChooseMoveContext's ctx.Done()
+	stopped int32           // 1 if done stopped a search, set atomically
+
+	// Pondering: searching the kept trees while the opponent thinks
+	ponderStop   chan struct{}
+	ponderDone   chan struct{}
+	predicted    int  // the most visited reply when pondering stopped
+	pondered     bool // until the opponent's move gets made
+	ponderHits   int
+	ponderMisses int
+}
+
+// Ways to choose moves in playouts
+const (
+	// HeavyPlayout players make a winning move if they have one,
+	// and avoid losing moves if they can.
+	HeavyPlayout = iota
+	// LightPlayout players make uniformly random moves.
+	LightPlayout
+	// EvaluatorPlayout players play like HeavyPlayout players,
+	// but pick the better of two random non-losing moves,
+	// by the A/B+Avoid player's static valuation.
+	EvaluatorPlayout
+)
+
+// Ways to choose the move to make once searching is done.
+// A proven winning move beats any of them.
+const (
+	// MostVisits picks the most visited root move.
+	MostVisits = iota
+	// BestRatio picks the root move with the best win ratio.
+	BestRatio
+	// RobustMax picks the root move that's both most visited and
+	// has the best win ratio, searching further, up to twice the
+	// iterations, until one move is both. Failing that, MostVisits.
+	RobustMax
+)
+
+// PlayoutPolicy turns the name of a playout policy,
+// light, heavy or evaluator, into one of the constants.
+func PlayoutPolicy(name string) (int, error) {
+	switch name {
+	case "heavy":
+		return HeavyPlayout, nil
+	case "light":
+		return LightPlayout, nil
+	case "evaluator":
+		return EvaluatorPlayout, nil
+	}
+	return 0, fmt.Errorf("unknown playout policy %q, light, heavy or evaluator", name)
+}
+
+// FinalSelection turns the name of a final move selection,
+// visits, ratio or robust, into one of the constants.
+func FinalSelection(name string) (int, error) {
+	switch name {
+	case "visits":
+		return MostVisits, nil
+	case "ratio":
+		return BestRatio, nil
+	case "robust":
+		return RobustMax, nil
+	}
+	return 0, fmt.Errorf("unknown final move selection %q, visits, ratio or robust", name)
 }
 
+// Ways for more than one worker goroutine to search
+const (
+	// RootParallel workers each search their own tree,
+	// and the trees' root moves' visits get added up.
+	RootParallel = iota
+	// TreeParallel workers all search one tree. A worker
+	// counts a visit to each node on its way down, before the
+	// playout's result is in, which steers other workers elsewhere.
+	TreeParallel
+)
+
+// Scores read wins and visits atomically, since
+// workers might be updating them.
+
 func ratio(node *Node) float64 {
-	return float64(node.wins) / float64(node.visits)
+	return float64(atomic.LoadInt64(&node.wins)) / float64(atomic.LoadInt64(&node.visits))
 }
 
-func ucb1(node *Node) float64 {
-	v := float64(node.visits)
+// ucb1 has exploration constant c
+func ucb1(node *Node, c float64) float64 {
+	v := float64(atomic.LoadInt64(&node.visits))
 	// Have to add 1 to node.parent.visits because back propagation
 	// doesn't happened until after the playout. The argument of math.Log()
 	// is supposed to be "the total number of simulations after the i-th move
 	// run by the parent node of the one considered"
-	return float64(node.wins)/v +
-		1.414*math.Sqrt(math.Log(float64(node.parent.visits+1))/v)
+	return float64(atomic.LoadInt64(&node.wins))/v +
+		c*math.Sqrt(math.Log(float64(atomic.LoadInt64(&node.parent.visits)+1))/v)
 }
 
+// DefaultExploration is the UCB1 exploration constant,
+// about sqrt(2), unless SetExploration says otherwise.
+const DefaultExploration = 1.414
+
+// DefaultRAVEEquivalence is the number of visits at which
+// RAVE scores give a node's own win ratio and its
+// all-moves-as-first win ratio equal weight.
+const DefaultRAVEEquivalence = 1000
+
+// raveScore blends a node's win ratio with its all-moves-as-first
+// win ratio, weighting the latter by beta, which starts at 1 and
+// shrinks as the node gets visits, halving at 1/3 of k visits:
+// beta = sqrt(k/(3*visits + k)). The UCB1 exploration term,
+// exploration constant c, gets added.
+func raveScore(node *Node, k float64, c float64) float64 {
+	v := float64(atomic.LoadInt64(&node.visits))
+	score := float64(atomic.LoadInt64(&node.wins)) / v
+	if amafVisits := atomic.LoadInt64(&node.amafVisits); amafVisits > 0 {
+		beta := math.Sqrt(k / (3*v + k))
+		score = (1-beta)*score + beta*float64(atomic.LoadInt64(&node.amafWins))/float64(amafVisits)
+	}
+	return score +
+		c*math.Sqrt(math.Log(float64(atomic.LoadInt64(&node.parent.visits)+1))/v)
+}
+
+// DefaultIterations is how many iterations a search runs, unless
+// the player's spec says otherwise.
+const DefaultIterations = 500000
+
+// NewMCTS makes a player that searches for iterations. With 0,
+// ChooseMoveContext searches until its ctx is done, and ChooseMove
+// for DefaultIterations.
 func NewMCTS(iterations int) *MCTS {
 	return &MCTS{
-		name:       "MCTS/Plain",
-		iterations: iterations,
-		scoreFn:    ratio,
+		name:        "MCTS/Plain",
+		iterations:  iterations,
+		scoreFn:     ratio,
+		exploration: DefaultExploration,
+		eval:        EvaluatorFunc(deltaValue2),
 	}
 }
 
 func (p *MCTS) SetUCB1() {
-	p.scoreFn = ucb1
+	p.scoreFn = func(node *Node) float64 { return ucb1(node, p.exploration) }
 	p.name = "MCTS/UCB1"
 }
 
+// SetExploration sets the exploration constant of UCB1 and RAVE
+// scores. Larger values search less promising moves more.
+// MCTS/Plain doesn't explore, its score is the win ratio.
+func (p *MCTS) SetExploration(c float64) {
+	p.exploration = c
+}
+
+// SetPlayout sets the playout policy:
+// HeavyPlayout, LightPlayout or EvaluatorPlayout.
+func (p *MCTS) SetPlayout(policy int) {
+	p.playoutPolicy = policy
+}
+
+// SetEvaluator sets the Evaluator that EvaluatorPlayout
+// playouts choose moves by, avoid unless set.
+func (p *MCTS) SetEvaluator(eval Evaluator) {
+	p.eval = eval
+}
+
+// SetFinalSelection sets how to pick the move to make
+// once searching is done: MostVisits, BestRatio or RobustMax.
+func (p *MCTS) SetFinalSelection(how int) {
+	p.finalSelection = how
+}
+
+// SetRAVE has the player score nodes by raveScore with equivalence
+// parameter k, so that moves get credit for playouts that made them
+// later on, not just for playouts that made them first: a marked
+// cell is marked, whenever it got marked.
+func (p *MCTS) SetRAVE(k float64) {
+	p.scoreFn = func(node *Node) float64 { return raveScore(node, k, p.exploration) }
+	p.rave = true
+	p.name = "MCTS/RAVE"
+}
+
 func (p *MCTS) Name() string {
 	return p.name
 }
 
+// SetTablebase has playouts end as soon as they reach a position in
+// tb, with its winner, and near enough the end of a game, has moves
+// chosen from tb rather than by searching. nil turns it off.
+func (p *MCTS) SetTablebase(tb *solution.Tablebase) {
+	p.tablebase = tb
+}
+
 func (p *MCTS) SetIterations(iterations int) {
 	p.iterations = iterations
 }
 
+// SetWorkers has searches split their iterations among workers
+// goroutines, searching in parallel the way mode says:
+// RootParallel or TreeParallel.
+func (p *MCTS) SetWorkers(workers int, mode int) {
+	p.workers = workers
+	p.parallel = mode
+	p.roots = nil
+}
+
+// MakeMove keeps the part of the tree under the move, if there is
+// one, to search from next time. The rest of the tree becomes garbage.
 func (p *MCTS) MakeMove(x, y int, player int) {
-	p.board[5*x+y] = player
+	cell := game.Cell(x, y)
+	p.StopPondering()
+	if p.pondered && player == MINIMIZER {
+		if cell == p.predicted {
+			p.ponderHits++
+		} else {
+			p.ponderMisses++
+		}
+	}
+	p.pondered = false
+	p.pos.MakeMove(cell, player)
+	for i, root := range p.roots {
+		p.roots[i] = root.child(cell)
+	}
+}
+
+// UnmakeMove takes back the last move made, by either player.
+// The kept tree is for the position after the move, so it goes.
+func (p *MCTS) UnmakeMove() {
+	p.StopPondering()
+	p.pondered = false
+	if p.pos.MoveNumber() > 0 {
+		p.pos.Unmake()
+	}
+	p.roots = nil
+}
+
+// Reset empties the board and the kept tree, for a new game.
+func (p *MCTS) Reset() {
+	p.StopPondering()
+	p.pondered = false
+	p.pos.Reset(MAXIMIZER)
+	p.roots = nil
+	p.inherited = 0
+}
+
+// SetPosition makes the board a copy of pos, sideToMove to move.
+// The kept tree, if any, is for some other position.
+func (p *MCTS) SetPosition(pos *game.Position, sideToMove int) {
+	p.StopPondering()
+	p.pondered = false
+	p.pos = *pos
+	p.pos.SetToMove(sideToMove)
+	p.roots = nil
+}
+
+// InheritedVisits returns the number of visits the roots of the
+// most recent ChooseMove's trees had from earlier moves' searches.
+func (p *MCTS) InheritedVisits() int {
+	return p.inherited
 }
 
 // ChooseMove should choose computer's next move and
 // return x,y coords of move and its score.
 func (p *MCTS) ChooseMove() (xcoord int, ycoord int, value int, leafcount int) {
+	xcoord, ycoord, value, leafcount, _ = p.ChooseMoveContext(context.Background())
+	return
+}
+
+// ChooseMoveContext chooses a move the way ChooseMove does, but
+// searching stops when ctx is done, and the move is the best
+// found so far. complete is false if ctx cut the iterations short,
+// which it always does for a player with 0 iterations.
+func (p *MCTS) ChooseMoveContext(ctx context.Context) (xcoord int, ycoord int, value int, leafcount int, complete bool) {
+
+	p.StopPondering()
+	p.pondered = false
+	p.done = ctx.Done()
+	atomic.StoreInt32(&p.stopped, 0)
+	defer func() {
+		p.done = nil
+	}()
 
 	var best int
 	var score float64
 
-	best, score, leafcount = bestMove(p.board, p.iterations, p.scoreFn, false)
+	p.inherited = 0
+	for _, root := range p.roots {
+		if root != nil {
+			p.inherited += int(root.visits)
+		}
+	}
+
+	best, score, leafcount = p.bestMove(false)
 
-	p.board[best] = MAXIMIZER
+	p.pos.MakeMove(best, MAXIMIZER)
 
-	// Since this implementations's "board" is a plain array, a move has to
-	// translate to <x,y> coords
-	xcoord = best / 5
-	ycoord = best % 5
+	// A move is a cell number, and has to translate to <x,y> coords
+	xcoord, ycoord = game.Coords(best)
 
 	value = int(score * 10000.)
+	complete = atomic.LoadInt32(&p.stopped) == 0
 
 	return
 }
 
-func bestMove(board [25]int, iterations int, scoreFn func(*Node) float64, verbose bool) (move int, score float64, leafCount int) {
+// Ponder searches the trees kept from the latest move, the opponent
+// to move, in the background until StopPondering. Whatever the
+// opponent's reply, the next search starts with the visits
+// pondering gave the tree under it.
+func (p *MCTS) Ponder() {
+	if p.ponderDone != nil || p.pos.Finished() {
+		return
+	}
+
+	board := p.pos
+	trees := 1
+	if p.workers > 1 && p.parallel == RootParallel {
+		trees = p.workers
+	}
+	for len(p.roots) < trees {
+		p.roots = append(p.roots, nil)
+	}
+	for i, root := range p.roots {
+		if root == nil {
+			p.roots[i] = &Node{
+				player:       -board.ToMove(), // made the last move
+				untriedMoves: board.EmptyCells(),
+			}
+		}
+	}
+
+	p.ponderStop = make(chan struct{})
+	p.ponderDone = make(chan struct{})
+	p.done = p.ponderStop
+	atomic.StoreInt32(&p.stopped, 0)
+	roots := p.roots
+	go func() {
+		defer close(p.ponderDone)
+		p.runSearch(roots, board, math.MaxInt32)
+	}()
+}
 
-	root := &Node{
-		player: MINIMIZER, // opponent made the last move
+// StopPondering stops the search Ponder started, if there is one,
+// and predicts the opponent's most visited reply.
+func (p *MCTS) StopPondering() {
+	if p.ponderDone == nil {
+		return
 	}
-	root.untriedMoves = make([]int, 0, 25)
-	for i := range board {
-		if board[i] == UNSET {
-			root.untriedMoves = append(root.untriedMoves, i)
+	close(p.ponderStop)
+	<-p.ponderDone
+	p.ponderStop, p.ponderDone, p.done = nil, nil, nil
+
+	p.predicted = -1
+	var visits int64
+	for _, c := range p.roots[0].childNodes {
+		if c.visits > visits {
+			p.predicted, visits = c.move, c.visits
 		}
 	}
+	p.pondered = true
+}
+
+// PonderStats returns how many of the opponent's moves were
+// the reply pondering visited most, and how many weren't.
+func (p *MCTS) PonderStats() (hits, misses int) {
+	return p.ponderHits, p.ponderMisses
+}
+
+// bestMove searches from the trees for p.pos kept from earlier
+// searches, or new trees if there aren't any. It keeps the subtrees
+// under the move it chooses, to reuse after the opponent's reply.
+func (p *MCTS) bestMove(verbose bool) (move int, score float64, leafCount int) {
+
+	board := p.pos
+	roots := p.roots
+	p.roots = nil
+
+	moves := board.EmptyCells()
 
-	w, l, o := categorizeMoves(&board, root.untriedMoves, MAXIMIZER)
+	w, l, o := categorizeMoves(&board, moves, MAXIMIZER)
+
+	// classes[m] is all the moves symmetric to m, if m is the
+	// lowest-numbered of them, empty otherwise. classOf[m]
+	// is the lowest-numbered move symmetric to m.
+	var classes [25][]int
+	var classOf [25]int
+	for _, class := range board.MoveClasses() {
+		classes[class[0]] = class
+		for _, m := range class {
+			classOf[m] = class[0]
+		}
+	}
 
 	// If there are winning moves, pick one of them.
 	if len(w) == 1 {
@@ -117,9 +460,15 @@ func bestMove(board [25]int, iterations int, scoreFn func(*Node) float64, verbos
 		return w[rand.Intn(len(w)-1)], 10000, 1
 	}
 
-	if len(o) > 0 {
-		root.untriedMoves = o
-	} else {
+	// MAXIMIZER moves first from root
+	board.SetToMove(MAXIMIZER)
+
+	// Every move leads to a position in the tablebase
+	if p.tablebase != nil && len(o) > 0 && len(moves)-1 <= p.tablebase.MaxEmpty() {
+		return tablebaseMove(&board, o, p.tablebase)
+	}
+
+	if len(o) == 0 {
 		// If there are only losing moves, pick one of them
 		if len(l) == 1 {
 			return l[0], -10000, 1
@@ -129,109 +478,439 @@ func bestMove(board [25]int, iterations int, scoreFn func(*Node) float64, verbos
 		}
 	}
 
-	state := &gameState{}
+	trees := 1
+	if p.workers > 1 && p.parallel == RootParallel {
+		trees = p.workers
+	}
+	for len(roots) < trees {
+		roots = append(roots, nil)
+	}
+	roots = roots[:trees]
+
+	for i, root := range roots {
+		if root == nil {
+			root = &Node{
+				player: MINIMIZER, // opponent made the last move
+			}
+			roots[i] = root
+		}
 
-	for iters := 0; iters < iterations; iters++ {
+		// Only one move of each class of symmetric moves gets
+		// expanded. A kept tree may already have expanded a
+		// class's move, not necessarily the lowest-numbered one.
+		var expanded [25]bool
+		for _, c := range root.childNodes {
+			expanded[classOf[c.move]] = true
+		}
+		root.untriedMoves = root.untriedMoves[:0]
+		for _, m := range o {
+			if len(classes[m]) > 0 && !expanded[m] {
+				root.untriedMoves = append(root.untriedMoves, m)
+			}
+		}
+	}
 
-		// reset state
-		for j := 0; j < 25; j++ {
-			state.board[j] = board[j]
+	iterations := p.iterations
+	if iterations == 0 {
+		iterations = DefaultIterations
+		if p.done != nil {
+			iterations = math.MaxInt32
 		}
-		state.player = MINIMIZER
+	}
 
-		node := root
+	leafCount = p.runSearch(roots, board, iterations)
+
+	var tallies [25]moveTally
+	var best int
+	for round := 0; ; round++ {
+		tallies = tallyMoves(roots, &classOf)
+		var settled bool
+		best, settled = p.finalMove(&tallies)
+		if settled || round == robustRounds || atomic.LoadInt32(&p.stopped) != 0 {
+			break
+		}
+		leafCount += p.runSearch(roots, board, (iterations+robustRounds-1)/robustRounds)
+	}
 
-		// Selection
-		for len(node.untriedMoves) == 0 && len(node.childNodes) > 0 {
-			node = node.selectBestChild(scoreFn)
-			state.makeMove(node.move)
+	if verbose {
+		for _, root := range roots {
+			fmt.Printf("after iterations root node %d/%d/%.3f\n", root.wins, root.visits, p.scoreFn(root))
+			fmt.Println("Child nodes:")
+			for _, c := range root.childNodes {
+				xcoord, ycoord := game.Coords(c.move)
+				fmt.Printf("\tmove %d <%d,%d>, player %d, %d/%d/%.3f, proven %d\n", c.move, xcoord, ycoord, c.player, c.wins, c.visits, p.scoreFn(c), c.proven)
+			}
 		}
+	}
+
+	score = tallies[best].ratio()
 
-		// node points to a Node struct that has no child nodes
-		// OR
-		// node points to a struct Node that has untried moves.
-		//
-		// state should represent the board resulting from following
-		// the "best child" nodes.
+	// Any move symmetric to the chosen move is as good. The trees
+	// under the chosen move get moved by the same symmetry.
+	class := classes[best]
+	move = class[rand.Intn(len(class))]
+	stabilizer := board.Stabilizer()
+	for _, root := range roots {
+		var moveNode *Node
+		for _, c := range root.childNodes {
+			if classOf[c.move] == best && (moveNode == nil || c.visits > moveNode.visits) {
+				moveNode = c
+			}
+		}
+		if moveNode == nil {
+			continue
+		}
+		for _, s := range stabilizer {
+			if game.Symmetries[s][moveNode.move] == move {
+				moveNode.transform(s)
+				break
+			}
+		}
+		moveNode.parent = nil
+		p.roots = append(p.roots, moveNode)
 
-		winner := findWinner(&(state.board))
+		if verbose {
+			fmt.Printf("\nbest move node move %d, player %d, %d/%d/%.3f\n", move, moveNode.player, moveNode.wins, moveNode.visits, score)
+		}
+	}
 
-		// Expansion will pick an untried move on the struct Node
-		// pointed to by Node, if it has untried moves. If node points to a
-		// struct Node reached by following "best child" nodes,
-		// node may not have untried moves.
-		if winner == UNSET && len(node.untriedMoves) > 0 {
-			mv := node.untriedMoves[rand.Intn(len(node.untriedMoves))]
+	return
+}
 
-			state.makeMove(mv)
+// runSearch searches roots for iterations, in total, one
+// tree, or the way p.parallel says if there are workers.
+func (p *MCTS) runSearch(roots []*Node, board game.Position, iterations int) (leafCount int) {
+	if p.workers <= 1 {
+		return p.search(roots[0], board, iterations, rand.New(rand.NewSource(rand.Int63())), false)
+	}
 
-			node = node.AddChild(mv, state) // AddChild take mv out of untriedMoves slice
-			winner = findWinner(&(state.board))
-			// node represents mv, the previously untried move
+	leafCounts := make([]int, p.workers)
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		// Split iterations as evenly as possible
+		share := iterations / p.workers
+		if i < iterations%p.workers {
+			share++
+		}
+		root, shared := roots[0], true
+		if p.parallel == RootParallel {
+			root, shared = roots[i], false
 		}
+		rng := rand.New(rand.NewSource(rand.Int63()))
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			leafCounts[i] = p.search(root, board, share, rng, shared)
+		}(i)
+	}
+	wg.Wait()
+	for _, n := range leafCounts {
+		leafCount += n
+	}
+	return
+}
 
-		// Simulation
-		// Heavy playout, in that moves get categorized,
-		// winners, losers and others for the player making
-		// the move. Players make winning moves if they can and avoid
-		// losing moves if they can.
-		if winner == UNSET {
-			moves := state.remainingMoves()
-
-			for len(moves) > 0 {
-				var m int
-				w, l, o := categorizeMoves(&(state.board), moves, 0-state.player)
-				if len(w) > 0 {
-					// Whoever can make a winning move for them should make it
-					m = w[rand.Intn(len(w))]
-					winner = 0 - state.player
-				} else if len(o) > 0 {
-					// Whoever can avoid a loosing move for them should make it
-					m = o[rand.Intn(len(o))]
-				} else {
-					m = l[rand.Intn(len(l))]
-					winner = state.player // state.player moved last, forced a loss
+// moveTally adds up the trees' counts for a class of symmetric moves
+type moveTally struct {
+	wins, visits int64
+	won, lost    bool // proven, in any tree
+	expanded     bool
+}
+
+func (t *moveTally) ratio() float64 {
+	return float64(t.wins) / float64(t.visits)
+}
+
+// RobustMax searches up to robustRounds more times,
+// each 1/robustRounds of the iterations.
+const robustRounds = 10
+
+// tallyMoves adds up the trees' counts for the root moves, by the
+// lowest-numbered move symmetric to each, classOf[move].
+func tallyMoves(roots []*Node, classOf *[25]int) (tallies [25]moveTally) {
+	for _, root := range roots {
+		for _, c := range root.childNodes {
+			t := &tallies[classOf[c.move]]
+			t.expanded = true
+			t.wins += c.wins
+			t.visits += c.visits
+			t.won = t.won || c.proven == provenWin
+			t.lost = t.lost || c.proven == provenLoss
+		}
+	}
+	return
+}
+
+// finalMove picks the class of moves to make from tallies, the way
+// p.finalSelection says. A proven win beats that, and a proven loss
+// only gets picked if all moves are. Returns false if RobustMax
+// wants more searching.
+func (p *MCTS) finalMove(tallies *[25]moveTally) (best int, settled bool) {
+	// subtle point in the Wikipedia article: select the move that
+	// had the most visits, not the best score.
+	mostVisited, bestRatio := -1, -1
+	for m := range tallies {
+		t := &tallies[m]
+		if !t.expanded {
+			continue
+		}
+		if t.won {
+			return m, true
+		}
+		if mostVisited < 0 || (tallies[mostVisited].lost && !t.lost) ||
+			(tallies[mostVisited].lost == t.lost && t.visits > tallies[mostVisited].visits) {
+			mostVisited = m
+		}
+		if bestRatio < 0 || (tallies[bestRatio].lost && !t.lost) ||
+			(tallies[bestRatio].lost == t.lost && t.ratio() > tallies[bestRatio].ratio()) {
+			bestRatio = m
+		}
+	}
+
+	switch p.finalSelection {
+	case BestRatio:
+		return bestRatio, true
+	case RobustMax:
+		return mostVisited, tallies[mostVisited].ratio() >= tallies[bestRatio].ratio()
+	}
+	return mostVisited, true
+}
+
+// search runs iterations of MCTS from root, the node for board,
+// stopping early if root gets proven, or ChooseMoveContext's ctx
+// is done after the first iteration. shared says whether
+// other workers are searching the same tree.
+func (p *MCTS) search(root *Node, board game.Position, iterations int, rng *rand.Rand, shared bool) (leafCount int) {
+
+	state := &game.Position{}
+
+	for iters := 0; iters < iterations && atomic.LoadInt32(&root.proven) == 0; iters++ {
+
+		if iters&63 == 1 && p.cancelled() {
+			break
+		}
+
+		// reset state
+		*state = board
+
+		node := root
+		winner := UNSET
+		if shared {
+			atomic.AddInt64(&root.visits, 1)
+		}
+
+		// Selection, which stops at proven nodes, and at nodes with
+		// untried moves, where expansion picks an untried move and
+		// adds a node for it. Nodes with neither untried moves nor
+		// child nodes have a full board.
+		for {
+			node.lock(shared)
+			if proven := atomic.LoadInt32(&node.proven); proven != 0 {
+				node.unlock(shared)
+				winner = node.player
+				if proven == provenLoss {
+					winner = -node.player
 				}
+				break
+			}
 
-				state.makeMove(m)
-				cutElement(&moves, m)
+			if len(node.untriedMoves) > 0 {
+				mv := node.untriedMoves[rng.Intn(len(node.untriedMoves))]
+				state.Make(mv)
+				child := node.AddChild(mv, state) // AddChild take mv out of untriedMoves slice
+				if shared {
+					child.visits = 1
+				}
+				node.unlock(shared)
 
+				// node represents mv, the previously untried move
+				node = child
+				winner = state.OutcomeAt(mv)
 				if winner != UNSET {
-					break
+					proven := int32(provenLoss)
+					if winner == node.player {
+						proven = provenWin
+					}
+					atomic.StoreInt32(&node.proven, proven)
+					node.parent.backUpProof(shared)
 				}
+				break
+			}
+
+			if len(node.childNodes) == 0 {
+				node.unlock(shared)
+				break
+			}
+
+			child := node.selectBestChild(p.scoreFn)
+			if child == nil {
+				// Another worker proved node's last unproven child
+				// a loss, under only that child's lock. node is
+				// proven now, and selection stops at it next time
+				// round the loop.
+				node.unlock(shared)
+				node.backUpProof(shared)
+				continue
+			}
+			if shared {
+				// Virtual loss: a visit without a win, until
+				// the playout says otherwise
+				atomic.AddInt64(&child.visits, 1)
 			}
+			node.unlock(shared)
+			node = child
+			state.Make(node.move)
+		}
+
+		// Simulation
+		if winner == UNSET {
+			winner = p.playout(state, rng)
 		}
 
 		leafCount++
 
-		for node != nil {
+		if p.rave {
+			node.updateAMAF(state, winner, shared)
+		}
+
+		// Backpropagation. Workers sharing the tree
+		// counted their visits on the way down.
+		for ; node != nil; node = node.parent {
+			if shared {
+				if winner == node.player {
+					atomic.AddInt64(&node.wins, 1)
+				}
+				continue
+			}
 			node.visits++
 			if winner == node.player {
 				node.wins++
 			}
-			node = node.parent
 		}
 	}
 
-	if verbose {
-		fmt.Printf("after iterations root node %d/%d/%.3f\n", root.wins, root.visits, scoreFn(root))
-		fmt.Println("Child nodes:")
-		for _, c := range root.childNodes {
-			xcoord := c.move / 5
-			ycoord := c.move % 5
-			fmt.Printf("\tmove %d <%d,%d>, player %d, %d/%d/%.3f\n", c.move, xcoord, ycoord, c.player, c.wins, c.visits, scoreFn(c))
+	return
+}
+
+// cancelled is true once ChooseMoveContext's ctx is done,
+// and marks the search as stopped.
+func (p *MCTS) cancelled() bool {
+	if isDone(p.done) {
+		atomic.StoreInt32(&p.stopped, 1)
+		return true
+	}
+	return false
+}
+
+// playout plays out the game from state, returning the winner,
+// UNSET for a cat game. Heavy playouts categorize moves as
+// winners, losers and others for the player making the move.
+// Players make winning moves if they can and avoid losing moves
+// if they can.
+func (p *MCTS) playout(state *game.Position, rng *rand.Rand) int {
+	tb := p.tablebase
+	moves := state.EmptyCells()
+
+	for len(moves) > 0 {
+		var m int
+		winner := UNSET
+		mover := state.ToMove()
+		if tb != nil && len(moves) <= tb.MaxEmpty() {
+			if v, ok := tb.Probe(state); ok {
+				switch v.Result() {
+				case solution.Win:
+					return mover
+				case solution.Loss:
+					return -mover
+				}
+				return UNSET
+			}
+		}
+
+		if p.playoutPolicy == LightPlayout {
+			m = moves[rng.Intn(len(moves))]
+			state.Make(m)
+			cutElement(&moves, m)
+			if winner = state.OutcomeAt(m); winner != UNSET {
+				return winner
+			}
+			continue
+		}
+
+		w, l, o := categorizeMoves(state, moves, mover)
+		if len(w) > 0 {
+			// Whoever can make a winning move for them should make it
+			m = w[rng.Intn(len(w))]
+			winner = mover
+		} else if len(o) > 0 {
+			// Whoever can avoid a loosing move for them should make it
+			m = o[rng.Intn(len(o))]
+			if p.playoutPolicy == EvaluatorPlayout && len(o) > 1 {
+				if other := o[rng.Intn(len(o))]; p.moveValue(state, other) > p.moveValue(state, m) {
+					m = other
+				}
+			}
+		} else {
+			m = l[rng.Intn(len(l))]
+			winner = -mover // -mover moved last, forced a loss
+		}
+
+		state.Make(m)
+		cutElement(&moves, m)
+
+		if winner != UNSET {
+			return winner
 		}
 	}
 
-	// subtle point in the Wikipedia article: select the move that
-	// had the most visits, not the best score.
-	moveNode := root.selectMostVisitedChild()
+	return UNSET
+}
 
-	score = scoreFn(moveNode)
-	move = moveNode.move
+// moveValue is the Evaluator's value of the player
+// to move marking cell, for that player.
+func (p *MCTS) moveValue(state *game.Position, cell int) int {
+	mover := state.ToMove()
+	state.Make(cell)
+	_, value := p.eval.Value(state, state.MoveNumber(), cell)
+	state.Unmake()
+	return mover * value
+}
 
-	if verbose {
-		fmt.Printf("\nbest move node move %d, player %d, %d/%d/%.3f\n", move, moveNode.player, moveNode.wins, moveNode.visits, score)
+// tablebaseMove picks the best of moves, none of which win or lose
+// right away, by looking up the positions they lead to in tb.
+// Moves of equal value get chosen at random. Score is 1 for
+// a win, 0 for a loss and 0.5 for a draw, like a win ratio.
+func tablebaseMove(board *game.Position, moves []int, tb *solution.Tablebase) (move int, score float64, leafCount int) {
+	var best []int
+	bestScore := 0
+
+	for _, m := range moves {
+		board.Make(m)
+		v := solution.MakeValue(solution.Draw, 1) // board full
+		if child, ok := tb.Probe(board); ok {
+			v = child.Parent()
+		}
+		board.Unmake()
+		leafCount++
+
+		switch s := v.Score(); {
+		case len(best) == 0 || s > bestScore:
+			best = append(best[:0], m)
+			bestScore = s
+		case s == bestScore:
+			best = append(best, m)
+		}
+	}
+
+	move = best[rand.Intn(len(best))]
+
+	switch {
+	case bestScore > 0:
+		score = 1
+	case bestScore < 0:
+		score = 0
+	default:
+		score = 0.5
 	}
 
 	return
@@ -253,11 +932,11 @@ func cutElement(ary *[]int, v int) {
 // 1. player wins
 // 2. other player wins, which means player chose a 3-in-a-row loss
 // 3. all other moves
-func categorizeMoves(board *[25]int, moves []int, player int) (wins []int, losses []int, other []int) {
+func categorizeMoves(board *game.Position, moves []int, player int) (wins []int, losses []int, other []int) {
 	for _, m := range moves {
-		(*board)[m] = player
-		x := findWinner(board)
-		(*board)[m] = UNSET
+		board.MakeMove(m, player)
+		x := board.OutcomeAt(m)
+		board.Unmake()
 		switch {
 		case x == UNSET:
 			other = append(other, m)
@@ -270,13 +949,12 @@ func categorizeMoves(board *[25]int, moves []int, player int) (wins []int, losse
 	return
 }
 
-func (node *Node) AddChild(mv int, state *gameState) *Node {
-	// fmt.Printf("node.AddChild(%d, %d)\n", mv, state.player)
+func (node *Node) AddChild(mv int, state *game.Position) *Node {
 	ch := &Node{
 		move:         mv,
 		parent:       node,
-		player:       state.player,
-		untriedMoves: state.remainingMoves(),
+		player:       -state.ToMove(), // made move mv
+		untriedMoves: state.EmptyCells(),
 	}
 	node.childNodes = append(node.childNodes, ch)
 	// weed out mv as an untried move
@@ -285,55 +963,126 @@ func (node *Node) AddChild(mv int, state *gameState) *Node {
 	return ch
 }
 
-func (node *Node) selectBestChild(scoreFn func(*Node) float64) *Node {
-	best := node.childNodes[0]
-	bestScore := scoreFn(node.childNodes[0])
-
-	// Since there's a maximum of 25 child nodes, just loop
-	// through them, rather than pay the overhead of sorting
-	// a small number of children.
+// child returns the child of node for move, if node has one,
+// cut loose from node so the rest of node's tree becomes garbage.
+func (node *Node) child(move int) *Node {
+	if node == nil {
+		return nil
+	}
 	for _, c := range node.childNodes {
-		score := scoreFn(c)
-		if score > bestScore {
-			best = c
-			bestScore = score
+		if c.move == move {
+			c.parent = nil
+			return c
 		}
 	}
-
-	return best
+	return nil
 }
 
-func (node *Node) selectMostVisitedChild() *Node {
-	best := node.childNodes[0]
-	mostVisits := node.childNodes[0].visits
-
+// transform moves every move in the tree under node, and node's
+// own move, by symmetry s.
+func (node *Node) transform(s int) {
+	node.move = game.Symmetries[s][node.move]
+	for i, m := range node.untriedMoves {
+		node.untriedMoves[i] = game.Symmetries[s][m]
+	}
 	for _, c := range node.childNodes {
-		if c.visits > mostVisits {
-			best = c
-			mostVisits = c.visits
+		c.transform(s)
+	}
+}
+
+// updateAMAF counts the playout that ended in state for every child
+// of node and of node's ancestors whose move got made by the child's
+// player, at that point in the game or later. Cells empty at a node
+// and marked in state got marked after the node.
+func (node *Node) updateAMAF(state *game.Position, winner int, shared bool) {
+	for ; node != nil; node = node.parent {
+		node.lock(shared)
+		for _, c := range node.childNodes {
+			if state.At(c.move) != c.player {
+				continue
+			}
+			if shared {
+				atomic.AddInt64(&c.amafVisits, 1)
+				if winner == c.player {
+					atomic.AddInt64(&c.amafWins, 1)
+				}
+				continue
+			}
+			c.amafVisits++
+			if winner == c.player {
+				c.amafWins++
+			}
 		}
+		node.unlock(shared)
 	}
+}
 
-	return best
+func (node *Node) lock(shared bool) {
+	if shared {
+		node.mu.Lock()
+	}
+}
+
+func (node *Node) unlock(shared bool) {
+	if shared {
+		node.mu.Unlock()
+	}
 }
 
-// remainingMoves returns an array of all moves left
-// unmade on state.board
-func (state *gameState) remainingMoves() []int {
-	mvs := make([]int, 0, 25)
-	j := 0
-	for i := 0; i < 25; i++ {
-		if state.board[i] == UNSET {
-			mvs = append(mvs, i)
-			j++
+// backUpProof checks whether node is proven, now that one
+// of its children is, and if so, checks node's parent, and so on.
+// A node is a proven loss if its opponent has a proven winning
+// move, a proven win if all its opponent's moves are proven losses.
+func (node *Node) backUpProof(shared bool) {
+	for ; node != nil && atomic.LoadInt32(&node.proven) == 0; node = node.parent {
+		var proven int32
+		node.lock(shared)
+		allLost := len(node.untriedMoves) == 0
+		for _, c := range node.childNodes {
+			childProven := atomic.LoadInt32(&c.proven)
+			if childProven == provenWin {
+				proven = provenLoss
+				break
+			}
+			if childProven != provenLoss {
+				allLost = false
+			}
+		}
+		node.unlock(shared)
+		if proven == 0 && allLost {
+			proven = provenWin
+		}
+		if proven == 0 {
+			return
 		}
+		atomic.StoreInt32(&node.proven, proven)
 	}
-	return mvs
 }
 
-func (state *gameState) makeMove(mv int) {
-	state.player = 0 - state.player
-	state.board[mv] = state.player
+// selectBestChild skips children proven to lose, returning nil
+// if they all are. Selection only gets here from an unproven node,
+// but workers sharing the tree prove children without locking
+// their parents, so the last unproven child can get proven a loss
+// between selection checking node and getting here.
+func (node *Node) selectBestChild(scoreFn func(*Node) float64) *Node {
+	var best *Node
+	var bestScore float64
+
+	// Since there's a maximum of 25 child nodes, just loop
+	// through them, rather than pay the overhead of sorting
+	// a small number of children.
+	for _, c := range node.childNodes {
+		if atomic.LoadInt32(&c.proven) == provenLoss {
+			continue
+		}
+		score := scoreFn(c)
+		if best == nil || score > bestScore {
+			best = c
+			bestScore = score
+		}
+	}
+
+	return best
 }
 
 func (p *MCTS) PrintBoard() {
@@ -346,105 +1095,9 @@ func (p *MCTS) SetScores(_ bool) {
 // FindWinner will return MAXIMIZER or MINIMIZER if somebody won,
 // UNSET if nobody wins based on current board.
 func (p *MCTS) FindWinner() int {
-	return findWinner(&(p.board))
-}
-
-// findWinner will return MAXIMIZER or MINIMIZER if somebody won,
-// UNSET if nobody wins based on argument board.
-// Pointer to [25]int to avoid creating copies of array that
-// don't get used.
-func findWinner(board *[25]int) int {
-	for _, i := range importantCells {
-		if (*board)[i] != UNSET {
-			for _, quad := range mctsWinningQuads[i] {
-				sum := (*board)[quad[0]] + (*board)[quad[1]] + (*board)[quad[2]] + (*board)[quad[3]]
-				switch sum {
-				case 4:
-					return MAXIMIZER
-				case -4:
-					return MINIMIZER
-				}
-			}
-		}
-	}
-	for _, i := range importantCells {
-		if (*board)[i] != UNSET {
-			for _, triplet := range mctsLosingTriplets[i] {
-				sum := (*board)[triplet[0]] + (*board)[triplet[1]] + (*board)[triplet[2]]
-				switch sum {
-				case 3:
-					return MINIMIZER
-				case -3:
-					return MAXIMIZER
-				}
-			}
-		}
-	}
-	return UNSET
+	return p.pos.Outcome()
 }
 
 func (p *MCTS) String() string {
-	return boardString(p.board)
-}
-
-// boardString exists as a separate function so that if
-// printf-style debugging is necessary, gameState.board
-// can also get printed.
-func boardString(board [25]int) string {
-	buf := &strings.Builder{}
-	buf.WriteString("   0 1 2 3 4\n")
-	for i := 0; i < 25; i++ {
-		if (i % 5) == 0 {
-			fmt.Fprintf(buf, "%c  ", rune(i/5)+'0')
-		}
-		fmt.Fprintf(buf, "%c ", "O_X"[board[i]+1])
-		if (i % 5) == 4 {
-			buf.WriteString("\n")
-		}
-	}
-	return buf.String()
-}
-
-var importantCells = [9]int{2, 7, 10, 11, 12, 13, 14, 17, 22}
-
-// 25 rows only to make looping easier. The filled-in
-// rows are the only quads you actually have to check
-// to find out if there's a win
-var mctsWinningQuads = [25][][]int{
-	{}, {},
-	{{0, 1, 2, 3}, {1, 2, 3, 4}, {2, 7, 12, 17}},
-	{}, {}, {}, {},
-	{{5, 6, 7, 8}, {6, 7, 8, 9}, {7, 12, 17, 22}},
-	{}, {},
-	{{0, 5, 10, 15}, {5, 10, 15, 20}},
-	{{1, 6, 11, 16}, {6, 11, 16, 21}, {3, 7, 11, 15}, {5, 11, 17, 23}},
-	{{10, 11, 12, 13}, {11, 12, 13, 14}, {4, 8, 12, 16}, {8, 12, 16, 20}, {0, 6, 12, 18}, {6, 12, 18, 24}},
-	{{3, 8, 13, 18}, {8, 13, 18, 23}, {1, 7, 13, 19}, {9, 13, 17, 21}},
-	{{4, 9, 14, 19}, {9, 14, 19, 24}},
-	{}, {},
-	{{15, 16, 17, 18}, {16, 17, 18, 19}},
-	{}, {}, {}, {},
-	{{20, 21, 22, 23}, {21, 22, 23, 24}},
-	{}, {},
-}
-
-// 25 rows only to make looping easier. The filled-in
-// rows are the only triplets you actually have to check
-// to find out if there's a loss.
-var mctsLosingTriplets = [][][]int{
-	{}, {},
-	{{0, 1, 2}, {1, 2, 3}, {2, 3, 4}, {2, 7, 12}, {2, 6, 10}, {14, 8, 2}},
-	{}, {}, {}, {},
-	{{5, 6, 7}, {6, 7, 8}, {7, 8, 9}, {2, 7, 12}, {7, 12, 17}, {3, 7, 11}, {7, 11, 15}, {1, 7, 13}, {7, 13, 19}},
-	{}, {},
-	{{10, 11, 12}, {0, 5, 10}, {5, 10, 15}, {10, 15, 20}, {2, 6, 10}, {10, 16, 22}},
-	{{10, 11, 12}, {11, 12, 13}, {1, 6, 11}, {6, 11, 16}, {11, 16, 21}, {3, 7, 11}, {7, 11, 15}, {5, 11, 17}, {11, 17, 23}},
-	{{10, 11, 12}, {11, 12, 13}, {12, 13, 14}, {2, 7, 12}, {7, 12, 17}, {12, 17, 22}, {0, 6, 12}, {6, 12, 18}, {12, 18, 24}, {4, 8, 12}, {8, 12, 16}, {12, 16, 20}},
-	{{11, 12, 13}, {12, 13, 14}, {3, 8, 13}, {8, 13, 18}, {13, 18, 23}, {1, 7, 13}, {7, 13, 19}, {21, 17, 13}, {17, 13, 9}},
-	{{12, 13, 14}, {4, 9, 14}, {9, 14, 19}, {14, 19, 24}, {22, 18, 14}, {14, 8, 2}},
-	{}, {},
-	{{15, 16, 17}, {16, 17, 18}, {17, 18, 19}, {7, 12, 17}, {12, 17, 22}, {5, 11, 17}, {11, 17, 23}, {21, 17, 13}, {17, 13, 9}},
-	{}, {}, {}, {},
-	{{20, 21, 22}, {21, 22, 23}, {22, 23, 24}, {12, 17, 22}, {10, 16, 22}, {22, 18, 14}},
-	{}, {},
+	return p.pos.String()
 }
@@ -0,0 +1,174 @@
+package players
+
+import (
+	"math/bits"
+	"math/rand"
+	"testing"
+
+	"squava2/game"
+)
+
+// X to move wins in two forced moves with 23, <4,3>, and only with it
+var forcedWin = []int{24, 5, 13, 17, 10, 12, 16, 6, 7, 20, 21, 19}
+
+// X to move loses to O at 5, <1,0>, after any move but 5
+var forcedBlock = []int{2, 10, 8, 15, 23, 0, 21, 22}
+
+func TestMCTSProvesForcedWin(t *testing.T) {
+	p := NewMCTS(benchIterations)
+	p.SetUCB1()
+	board := *game.MoverView(forcedWin)
+
+	// MINIMIZER made the last move, and has lost
+	root := &Node{player: MINIMIZER, untriedMoves: board.EmptyCells()}
+	p.search(root, board, benchIterations, rand.New(rand.NewSource(1)), false)
+	if root.proven != provenLoss {
+		t.Errorf("root proven %d, want a loss, %d", root.proven, provenLoss)
+	}
+	for _, c := range root.childNodes {
+		if want := c.move == 23; (c.proven == provenWin) != want {
+			t.Errorf("move %d proven %d, only 23 is a proven win", c.move, c.proven)
+		}
+	}
+
+	p.SetPosition(&board, MAXIMIZER)
+	x, y, _, _ := p.ChooseMove()
+	if cell := game.Cell(x, y); cell != 23 {
+		t.Errorf("chose <%d,%d>, want the win, <4,3>", x, y)
+	}
+	if kept := p.roots[0]; kept.proven != provenWin {
+		t.Errorf("chosen move proven %d, want a win, %d", kept.proven, provenWin)
+	}
+}
+
+func TestMCTSAvoidsProvenLosses(t *testing.T) {
+	for _, spec := range []string{
+		"mcts",
+		"mcts:ucb1",
+		"mcts:rave",
+		"mcts:ucb1,threads=4",
+		"mcts:ucb1,threads=4,parallel=tree",
+	} {
+		player, err := NewPlayer(spec, Env{Defaults: "iters=5000"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := player.(*MCTS)
+		p.SetPosition(game.MoverView(forcedBlock), MAXIMIZER)
+		x, y, _, _ := p.ChooseMove()
+		if cell := game.Cell(x, y); cell != 5 {
+			t.Errorf("%s chose <%d,%d>, a proven loss, not the block, <1,0>", spec, x, y)
+		}
+	}
+}
+
+// TestSelectBestChild checks that selection skips children proven
+// to lose, whatever their scores, and finds none if they all are.
+func TestSelectBestChild(t *testing.T) {
+	node := &Node{}
+	for move := 0; move < 3; move++ {
+		node.childNodes = append(node.childNodes, &Node{move: move, parent: node, proven: provenLoss, wins: 9, visits: 10})
+	}
+	node.childNodes[1].proven = 0
+	node.childNodes[1].wins = 0
+
+	if best := node.selectBestChild(ratio); best != node.childNodes[1] {
+		t.Errorf("selected %+v, want the only child not proven a loss", best)
+	}
+
+	node.childNodes[1].proven = provenLoss
+	if best := node.selectBestChild(ratio); best != nil {
+		t.Errorf("selected %+v, want none, all are proven losses", best)
+	}
+	node.backUpProof(false)
+	if node.proven != provenWin {
+		t.Errorf("node whose children all lose proven %d, want a win, %d", node.proven, provenWin)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	for s := range game.Symmetries {
+		node := &Node{move: 6, untriedMoves: []int{0, 3, 24}}
+		node.childNodes = []*Node{
+			{move: 1, parent: node, untriedMoves: []int{2, 4}},
+			{move: 12, parent: node},
+		}
+		node.childNodes[0].childNodes = []*Node{{move: 7, parent: node.childNodes[0]}}
+
+		node.transform(s)
+		sym := &game.Symmetries[s]
+		if node.move != sym[6] {
+			t.Errorf("symmetry %d moved node's move 6 to %d, want %d", s, node.move, sym[6])
+		}
+		for i, m := range []int{0, 3, 24} {
+			if node.untriedMoves[i] != sym[m] {
+				t.Errorf("symmetry %d moved untried move %d to %d, want %d", s, m, node.untriedMoves[i], sym[m])
+			}
+		}
+		for i, m := range []int{1, 12} {
+			if c := node.childNodes[i]; c.move != sym[m] {
+				t.Errorf("symmetry %d moved child move %d to %d, want %d", s, m, c.move, sym[m])
+			}
+		}
+		if c := node.childNodes[0]; c.untriedMoves[0] != sym[2] || c.untriedMoves[1] != sym[4] || c.childNodes[0].move != sym[7] {
+			t.Errorf("symmetry %d moved grandchildren 2, 4, 7 to %v, %d", s, c.untriedMoves, c.childNodes[0].move)
+		}
+	}
+}
+
+// TestMCTSTreeReuse plays a move and a reply from the empty board,
+// where the move chosen is any of a class of symmetric moves, and
+// the tree kept under it has to get moved by the same symmetry.
+func TestMCTSTreeReuse(t *testing.T) {
+	for trial := 0; trial < 8; trial++ {
+		p := NewMCTS(2000)
+		p.SetUCB1()
+		x, y, _, _ := p.ChooseMove()
+		kept := p.roots[0]
+		if move := game.Cell(x, y); kept.move != move {
+			t.Fatalf("chose %d, kept the tree for %d", move, kept.move)
+		}
+		checkTree(t, kept, p.pos)
+
+		// The opponent replies with its most visited move
+		var reply *Node
+		for _, c := range kept.childNodes {
+			if reply == nil || c.visits > reply.visits {
+				reply = c
+			}
+		}
+		visits := int(reply.visits)
+		rx, ry := game.Coords(reply.move)
+		p.MakeMove(rx, ry, MINIMIZER)
+		checkTree(t, p.roots[0], p.pos)
+
+		p.ChooseMove()
+		if p.InheritedVisits() != visits {
+			t.Errorf("search after reply inherited %d visits, the reply's node had %d", p.InheritedVisits(), visits)
+		}
+	}
+}
+
+// checkTree checks that the moves of node's children and its
+// untried moves are the empty cells of pos, node's position,
+// each once, and the same for node's children.
+func checkTree(t *testing.T, node *Node, pos game.Position) {
+	t.Helper()
+	var moves uint32
+	for _, c := range node.childNodes {
+		moves |= 1 << c.move
+	}
+	for _, m := range node.untriedMoves {
+		moves |= 1 << m
+	}
+	if n := len(node.childNodes) + len(node.untriedMoves); moves != pos.Empty() || n != bits.OnesCount32(pos.Empty()) {
+		t.Fatalf("node for move %d has moves %#x, %d of them, the board has empty cells %#x:\n%s", node.move, moves, n, pos.Empty(), &pos)
+	}
+	for _, c := range node.childNodes {
+		child := pos
+		child.Make(c.move)
+		if len(c.childNodes) > 0 {
+			checkTree(t, c, child)
+		}
+	}
+}
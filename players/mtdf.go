@@ -0,0 +1,41 @@
+package players
+
+/*
+ * MTD(f): find a position's value with nothing but null-window
+ * alpha/beta searches. Each one says whether the value is above or
+ * below a guess, and the next guess is the bound it returns. Squava
+ * values bunch up around a few numbers, WIN or LOSS give or take the
+ * ply, and small deltaValue or deltaValue2 offsets from 0, so a good
+ * first guess often takes only a few searches. The transposition table
+ * keeps each search's bounds, so later searches go mostly to the table.
+ */
+
+// SetMTDF has ChooseMove value root moves with MTD(f)
+// instead of a full-window alpha/beta search of each.
+func (p *AlphaBeta) SetMTDF(on bool) {
+	p.mtdf = on
+}
+
+// mtdfValue finds the value of the root move just made, which has
+// static value boardValue, first guessing the latest root move's value.
+func (p *AlphaBeta) mtdfValue(boardValue int) int {
+	lower, upper := 2*LOSS, 2*WIN
+	g := p.guess
+	for lower < upper {
+		beta := g
+		if g == lower {
+			beta = g + 1
+		}
+		g = p.alphaBeta(2, MINIMIZER, beta-1, beta, boardValue)
+		if p.stopped {
+			return 0
+		}
+		if g < beta {
+			upper = g
+		} else {
+			lower = g
+		}
+	}
+	p.guess = g
+	return g
+}
@@ -0,0 +1,113 @@
+package players
+
+import (
+	"math/bits"
+
+	"squava2/game"
+)
+
+/*
+ * Move ordering for alpha/beta: the sooner the move that gets a
+ * cutoff gets searched, the fewer of its siblings get searched.
+ * First the transposition table's best move, then the killer moves
+ * of the ply, moves that got cutoffs in sibling positions, then the
+ * rest by history score, credit for cutoffs anywhere in the search,
+ * with a static pre-score of each cell breaking ties.
+ */
+
+// killerCount is how many killer moves each ply keeps
+const killerCount = 2
+
+// preScores are the number of 4-in-a-row lines through each cell,
+// more for cells nearer the center.
+var preScores [25]int
+
+func init() {
+	for cell := range preScores {
+		preScores[cell] = len(game.QuadMasksAt[cell])
+	}
+}
+
+// SetMoveOrdering turns killer moves, history scores and static
+// pre-scores on or off. They're on unless turned off. Off, searches
+// look at the table's best move first, and the rest in cell order.
+func (p *AlphaBeta) SetMoveOrdering(on bool) {
+	p.plainOrder = !on
+}
+
+// newOrdering forgets killer moves, which were for the previous
+// move's positions, and halves history scores, so that they
+// favor moves that got cutoffs recently.
+func (p *AlphaBeta) newOrdering() {
+	for ply := range p.killers {
+		for k := range p.killers[ply] {
+			p.killers[ply][k] = -1
+		}
+	}
+	for s := range p.history {
+		for cell := range p.history[s] {
+			p.history[s][cell] /= 2
+		}
+	}
+}
+
+// orderMoves puts the cells of empty in moves, in the order
+// alphaBeta searches them at ply, player to move, first first,
+// if it's empty. Returns the number of moves.
+func (p *AlphaBeta) orderMoves(ply int, player int, empty uint32, first int, moves *[25]int) int {
+	n := 0
+	if first >= 0 && empty&(1<<first) != 0 {
+		moves[n] = first
+		n++
+		empty &^= 1 << first
+	}
+
+	if p.plainOrder {
+		for empty != 0 {
+			cell := bits.TrailingZeros32(empty)
+			empty &^= 1 << cell
+			moves[n] = cell
+			n++
+		}
+		return n
+	}
+
+	for _, cell := range p.killers[ply] {
+		if cell >= 0 && empty&(1<<cell) != 0 {
+			moves[n] = cell
+			n++
+			empty &^= 1 << cell
+		}
+	}
+
+	// Insertion sort the rest, best score first
+	history := &p.history[(player+1)/2]
+	start := n
+	for empty != 0 {
+		cell := bits.TrailingZeros32(empty)
+		empty &^= 1 << cell
+		score := history[cell] + preScores[cell]
+		i := n
+		for ; i > start && history[moves[i-1]]+preScores[moves[i-1]] < score; i-- {
+			moves[i] = moves[i-1]
+		}
+		moves[i] = cell
+		n++
+	}
+	return n
+}
+
+// rememberCutoff makes cell, player's move at ply with depth
+// plies left to search, a killer move at ply, and adds to its
+// history score, more for deeper searches.
+func (p *AlphaBeta) rememberCutoff(ply int, player int, cell int, depth int) {
+	if p.plainOrder {
+		return
+	}
+	killers := &p.killers[ply]
+	if killers[0] != cell {
+		copy(killers[1:], killers[:killerCount-1])
+		killers[0] = cell
+	}
+	p.history[(player+1)/2][cell] += depth * depth
+}
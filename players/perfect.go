@@ -0,0 +1,142 @@
+package players
+
+import (
+	"context"
+	"math/rand"
+
+	"squava2/game"
+	"squava2/solution"
+)
+
+/*
+ * Perfect play, looking up the value of every move in a
+ * solution database, and solving whatever isn't in the
+ * database. Late in a game that's quick, but early positions
+ * not in the database can take an impractically long time.
+ */
+
+type Perfect struct {
+	name          string
+	pos           game.Position
+	solver        *solution.Solver
+	deterministic bool
+}
+
+// NewPerfect makes a Perfect player that looks up values in db,
+// which can be nil. It never adds positions to db.
+func NewPerfect(db *solution.DB, deterministic bool) *Perfect {
+	return &Perfect{
+		name:          "Perfect",
+		solver:        solution.NewSolver(db, -1, 64<<20),
+		deterministic: deterministic,
+	}
+}
+
+func (p *Perfect) Name() string {
+	return p.name
+}
+
+func (p *Perfect) MakeMove(x, y int, player int) {
+	p.pos.MakeMove(game.Cell(x, y), player)
+}
+
+// UnmakeMove takes back the last move made, by either player.
+func (p *Perfect) UnmakeMove() {
+	if p.pos.MoveNumber() > 0 {
+		p.pos.Unmake()
+	}
+}
+
+// Reset empties the board for a new game.
+func (p *Perfect) Reset() {
+	p.pos.Reset(MAXIMIZER)
+}
+
+// SetPosition makes the board a copy of pos, sideToMove to move.
+func (p *Perfect) SetPosition(pos *game.Position, sideToMove int) {
+	p.pos = *pos
+	p.pos.SetToMove(sideToMove)
+}
+
+// ChooseMove picks the fastest win, or failing that a draw,
+// or failing that the slowest loss. Moves of equal value get
+// chosen at random, unless the player is deterministic, when
+// the lowest-numbered cell wins. Value is the move's solution
+// score, leafcount the number of positions the solver searched.
+func (p *Perfect) ChooseMove() (xcoord int, ycoord int, value int, leafcount int) {
+	xcoord, ycoord, value, leafcount, _ = p.ChooseMoveContext(context.Background())
+	return
+}
+
+// ChooseMoveContext chooses a move the way ChooseMove does, but
+// once ctx is done, it stops valuing moves, and picks the best of
+// the moves valued so far. Solving one move's position can't stop
+// part way. complete is false if ctx left moves unvalued.
+func (p *Perfect) ChooseMoveContext(ctx context.Context) (xcoord int, ycoord int, value int, leafcount int, complete bool) {
+
+	p.pos.SetToMove(MAXIMIZER)
+	nodes := p.solver.Nodes
+
+	var best []int
+	bestScore := 0
+	complete = true
+
+	for _, cell := range p.pos.EmptyCells() {
+		if len(best) > 0 && ctx.Err() != nil {
+			complete = false
+			break
+		}
+		v := p.moveValue(cell)
+		score := v.Score()
+		switch {
+		case len(best) == 0 || score > bestScore:
+			best = append(best[:0], cell)
+			bestScore = score
+		case score == bestScore:
+			best = append(best, cell)
+		}
+	}
+
+	move := best[0]
+	if !p.deterministic {
+		move = best[rand.Intn(len(best))]
+	}
+
+	p.pos.MakeMove(move, MAXIMIZER)
+
+	xcoord, ycoord = game.Coords(move)
+
+	return xcoord, ycoord, bestScore, p.solver.Nodes - nodes, complete
+}
+
+// moveValue returns the value to the player to move
+// of marking cell.
+func (p *Perfect) moveValue(cell int) solution.Value {
+	player := p.pos.ToMove()
+	p.pos.Make(cell)
+	defer p.pos.Unmake()
+
+	switch p.pos.OutcomeAt(cell) {
+	case player:
+		return solution.MakeValue(solution.Win, 1)
+	case -player:
+		return solution.MakeValue(solution.Loss, 1)
+	}
+	if p.pos.MoveNumber() == 25 {
+		return solution.MakeValue(solution.Draw, 1)
+	}
+
+	v, _ := p.solver.Solve(&p.pos)
+	return v.Parent()
+}
+
+// FindWinner returns the winner of the current game,
+// if any, based on internal board representation
+func (p *Perfect) FindWinner() int {
+	return p.pos.Outcome()
+}
+
+// String returns the board in a human-readable fashion.
+func (p *Perfect) String() string {
+	return p.pos.String()
+}
@@ -0,0 +1,633 @@
+package players
+
+import (
+	"context"
+	"math/bits"
+	"time"
+
+	"squava2/game"
+)
+
+/*
+ * Proof-number search: tries to prove that the player to move
+ * can force a win. Every position is an OR node, one of our moves
+ * has to win, or an AND node, all of the opponent's moves have to
+ * lose. A node's proof number is how many more leaf positions at
+ * least have to turn out wins to prove it, its disproof number how
+ * many have to turn out losses or draws to disprove it. Search
+ * always expands the most-proving position, the one that helps
+ * prove or disprove the root most cheaply.
+ * From: Allis, van der Meulen, van den Herik, "Proof-Number Search",
+ * and for the depth-first variant, Seo, Iida, Uiterwijk, "The PN*-search
+ * algorithm", as reformulated by Nagai's df-pn.
+ */
+
+// infinity is a proof or disproof number too big to ever reach.
+const infinity = 1 << 30
+
+// DefaultProofNodes is the node budget a PNS player gets
+// unless SetNodeBudget says otherwise.
+const DefaultProofNodes = 1000000
+
+type PNS struct {
+	name       string
+	pos        game.Position
+	nodeBudget int
+	nodeCount  int
+	fallback   Player
+	depthFirst bool
+	table      *pnTable
+	done       <-chan struct{} // ChooseMoveContext's ctx.Done()
+	stopped    bool            // done stopped the search
+}
+
+// NewPNS makes a best-first proof-number search player. When it
+// can't prove a win inside its node budget, fallback chooses the move.
+// A nil fallback means playing the move that came closest to a proof.
+func NewPNS(fallback Player) *PNS {
+	return &PNS{
+		name:       "PNS",
+		nodeBudget: DefaultProofNodes,
+		fallback:   fallback,
+	}
+}
+
+// SetDepthFirst switches to PN*, depth-first proof-number search,
+// which keeps proof and disproof numbers in a table that fits in
+// budget bytes rather than keeping the whole search tree.
+func (p *PNS) SetDepthFirst(budget int) {
+	p.depthFirst = true
+	p.table = newPNTable(budget)
+	p.name = "PN*"
+}
+
+// SetNodeBudget sets how many positions a search can
+// look at before giving up on proving a win.
+func (p *PNS) SetNodeBudget(nodes int) {
+	p.nodeBudget = nodes
+}
+
+func (p *PNS) Name() string {
+	return p.name
+}
+
+func (p *PNS) MakeMove(x, y int, player int) {
+	p.pos.MakeMove(game.Cell(x, y), player)
+	if p.fallback != nil {
+		p.fallback.MakeMove(x, y, player)
+	}
+}
+
+// ChooseMove plays a winning move if search proves it has one, with
+// value WIN and leafcount the number of leaf positions in the proof.
+// Otherwise it's the fallback player's move, value and leaf count.
+func (p *PNS) ChooseMove() (xcoord int, ycoord int, value int, leafcount int) {
+	xcoord, ycoord, value, leafcount, _ = p.ChooseMoveContext(context.Background())
+	return
+}
+
+// ChooseMoveContext chooses a move the way ChooseMove does, but
+// proving stops when ctx is done, and the fallback gets ctx too.
+// Given a deadline, proving gets half the time, the fallback the
+// rest. complete is false if ctx stopped either search.
+func (p *PNS) ChooseMoveContext(ctx context.Context) (xcoord int, ycoord int, value int, leafcount int, complete bool) {
+
+	p.pos.SetToMove(MAXIMIZER)
+	p.nodeCount = 0
+	proving := ctx
+	if deadline, ok := ctx.Deadline(); ok && p.fallback != nil {
+		var cancel context.CancelFunc
+		proving, cancel = context.WithDeadline(ctx, time.Now().Add(time.Until(deadline)/2))
+		defer cancel()
+	}
+	p.done, p.stopped = proving.Done(), false
+	defer func() {
+		p.done = nil
+	}()
+
+	var move, proofSize int
+	var proved bool
+	if p.depthFirst {
+		move, proofSize, proved = p.depthFirstSearch()
+	} else {
+		move, proofSize, proved = p.bestFirstSearch()
+	}
+
+	if proved {
+		xcoord, ycoord = game.Coords(move)
+		p.pos.MakeMove(move, MAXIMIZER)
+		if p.fallback != nil {
+			p.fallback.MakeMove(xcoord, ycoord, MAXIMIZER)
+		}
+		return xcoord, ycoord, WIN, proofSize, true
+	}
+
+	if p.fallback != nil {
+		xcoord, ycoord, value, leafcount, complete = p.fallback.ChooseMoveContext(ctx)
+		p.pos.MakeMove(game.Cell(xcoord, ycoord), MAXIMIZER)
+		return xcoord, ycoord, value, leafcount, complete && !p.stopped
+	}
+
+	xcoord, ycoord = game.Coords(move)
+	p.pos.MakeMove(move, MAXIMIZER)
+	return xcoord, ycoord, 0, p.nodeCount, !p.stopped
+}
+
+// outOfNodes is true once the search has looked at its budget of
+// positions, or ChooseMoveContext's ctx is done.
+func (p *PNS) outOfNodes() bool {
+	if !p.stopped && isDone(p.done) {
+		p.stopped = true
+	}
+	return p.stopped || p.nodeCount >= p.nodeBudget
+}
+
+// Ponder has the fallback ponder, if it can. Proving starts
+// over every move, so there's nothing for PNS itself to ponder.
+func (p *PNS) Ponder() {
+	if f, ok := p.fallback.(Ponderer); ok {
+		f.Ponder()
+	}
+}
+
+// StopPondering stops the fallback pondering.
+func (p *PNS) StopPondering() {
+	if f, ok := p.fallback.(Ponderer); ok {
+		f.StopPondering()
+	}
+}
+
+// PonderStats returns the fallback's ponder hits and misses.
+func (p *PNS) PonderStats() (hits, misses int) {
+	if f, ok := p.fallback.(Ponderer); ok {
+		return f.PonderStats()
+	}
+	return 0, 0
+}
+
+// UnmakeMove takes back the last move, on the fallback's board too.
+func (p *PNS) UnmakeMove() {
+	if p.pos.MoveNumber() > 0 {
+		p.pos.Unmake()
+	}
+	if p.fallback != nil {
+		p.fallback.UnmakeMove()
+	}
+}
+
+// Reset empties the board, and the fallback's, for a new game.
+// PN*'s table keeps its entries, they're good in any game.
+func (p *PNS) Reset() {
+	p.pos.Reset(MAXIMIZER)
+	if p.fallback != nil {
+		p.fallback.Reset()
+	}
+}
+
+// SetPosition makes the board, and the fallback's,
+// a copy of pos, sideToMove to move.
+func (p *PNS) SetPosition(pos *game.Position, sideToMove int) {
+	p.pos = *pos
+	p.pos.SetToMove(sideToMove)
+	if p.fallback != nil {
+		p.fallback.SetPosition(pos, sideToMove)
+	}
+}
+
+// FindWinner returns the winner of the current game,
+// if any, based on internal board representation
+func (p *PNS) FindWinner() int {
+	return p.pos.Outcome()
+}
+
+// String returns the board in a human-readable fashion.
+func (p *PNS) String() string {
+	return p.pos.String()
+}
+
+// What the player to move can expect from a position,
+// before any search
+const (
+	unknownResult = iota
+	moverWins
+	moverLoses
+	catGame
+)
+
+// pnMoves finds out if the player to move in pos wins or loses
+// right away, and if not, which moves are worth searching: blocks
+// of the opponent's winning cells if the opponent has any, moves
+// that don't make 3 in a row, and only one move of each class
+// of symmetric moves.
+func pnMoves(pos *game.Position) (result int, moves []int) {
+	player := pos.ToMove()
+	mine, theirs := pos.Marks(player), pos.Marks(-player)
+	empty := pos.Empty()
+	if empty == 0 {
+		return catGame, nil
+	}
+
+	var threats uint32
+	for e := empty; e != 0; e &= e - 1 {
+		cell := bits.TrailingZeros32(e)
+		if hasLine(mine|1<<cell, game.QuadMasksAt[cell]) {
+			return moverWins, nil
+		}
+		if hasLine(theirs|1<<cell, game.QuadMasksAt[cell]) {
+			threats |= 1 << cell
+		}
+	}
+
+	candidates := empty
+	if threats != 0 {
+		candidates = threats // anything else loses next move
+	}
+
+	var safe uint32
+	for c := candidates; c != 0; c &= c - 1 {
+		cell := bits.TrailingZeros32(c)
+		if !hasLine(mine|1<<cell, game.TripletMasksAt[cell]) {
+			safe |= 1 << cell
+		}
+	}
+	if safe == 0 {
+		return moverLoses, nil
+	}
+
+	for _, class := range pos.MoveClasses() {
+		if safe&(1<<class[0]) != 0 {
+			moves = append(moves, class[0])
+		}
+	}
+	return unknownResult, moves
+}
+
+func hasLine(marks uint32, masks []uint32) bool {
+	for _, m := range masks {
+		if marks&m == m {
+			return true
+		}
+	}
+	return false
+}
+
+// numbers returns proof and disproof numbers for a position
+// whose result for the player to move, if any, is known.
+// or is true if it's our move in the position.
+func numbers(result int, or bool) (pn, dn int) {
+	switch result {
+	case moverWins:
+		if or {
+			return 0, infinity
+		}
+		return infinity, 0
+	case moverLoses:
+		if or {
+			return infinity, 0
+		}
+		return 0, infinity
+	case catGame:
+		return infinity, 0
+	}
+	return 1, 1
+}
+
+func addNumbers(a, b int) int {
+	if a+b > infinity {
+		return infinity
+	}
+	return a + b
+}
+
+// unsearched handles positions with nothing to search: a winning
+// move is there to make, or every move loses.
+func (p *PNS) unsearched(result int) (move int, proofSize int, proved bool) {
+	mine := p.pos.Marks(MAXIMIZER)
+	for _, cell := range p.pos.EmptyCells() {
+		if result == moverWins && hasLine(mine|1<<cell, game.QuadMasksAt[cell]) {
+			return cell, 1, true
+		}
+	}
+	return p.pos.EmptyCells()[0], 0, false
+}
+
+/*
+ * Best-first search, keeping the whole tree
+ */
+
+type pnNode struct {
+	move     int
+	or       bool // our move
+	pn, dn   int
+	parent   *pnNode
+	children []*pnNode
+}
+
+func (p *PNS) bestFirstSearch() (move int, proofSize int, proved bool) {
+	root := &pnNode{move: -1, or: true}
+	result, moves := pnMoves(&p.pos)
+	root.pn, root.dn = numbers(result, true)
+	if result != unknownResult {
+		return p.unsearched(result)
+	}
+	p.expand(root, moves)
+
+	for root.pn != 0 && root.dn != 0 && !p.outOfNodes() {
+		// Select the most-proving node
+		node := root
+		for node.children != nil {
+			node = node.mostProving()
+			p.pos.Make(node.move)
+		}
+
+		_, moves := pnMoves(&p.pos)
+		p.expand(node, moves)
+
+		// Update ancestors, back to the root position
+		for ; node != root; node = node.parent {
+			node.update()
+			p.pos.Unmake()
+		}
+		root.update()
+	}
+
+	best := root.mostProving()
+	if root.pn == 0 {
+		for _, child := range root.children {
+			if child.pn == 0 {
+				best = child
+				break
+			}
+		}
+		return best.move, best.proofSize(), true
+	}
+	return best.move, 0, false
+}
+
+// expand gives node a child for each of moves, with
+// proof and disproof numbers for the positions they lead to.
+func (p *PNS) expand(node *pnNode, moves []int) {
+	node.children = make([]*pnNode, len(moves))
+	for i, m := range moves {
+		p.pos.Make(m)
+		result, _ := pnMoves(&p.pos)
+		p.pos.Unmake()
+		p.nodeCount++
+
+		child := &pnNode{move: m, or: !node.or, parent: node}
+		child.pn, child.dn = numbers(result, child.or)
+		node.children[i] = child
+	}
+	node.update()
+}
+
+// update sets node's proof and disproof numbers from its children's.
+func (node *pnNode) update() {
+	if node.or {
+		node.pn, node.dn = infinity, 0
+		for _, child := range node.children {
+			if child.pn < node.pn {
+				node.pn = child.pn
+			}
+			node.dn = addNumbers(node.dn, child.dn)
+		}
+		return
+	}
+	node.pn, node.dn = 0, infinity
+	for _, child := range node.children {
+		node.pn = addNumbers(node.pn, child.pn)
+		if child.dn < node.dn {
+			node.dn = child.dn
+		}
+	}
+}
+
+// mostProving returns the child that's cheapest to prove
+// if it's our move, cheapest to disprove if it isn't.
+func (node *pnNode) mostProving() *pnNode {
+	best := node.children[0]
+	for _, child := range node.children[1:] {
+		if node.or && child.pn < best.pn || !node.or && child.dn < best.dn {
+			best = child
+		}
+	}
+	return best
+}
+
+// proofSize counts the leaves of the proof tree under a proved node.
+func (node *pnNode) proofSize() int {
+	if node.children == nil {
+		return 1
+	}
+	if node.or {
+		for _, child := range node.children {
+			if child.pn == 0 {
+				return child.proofSize()
+			}
+		}
+	}
+	size := 0
+	for _, child := range node.children {
+		size += child.proofSize()
+	}
+	return size
+}
+
+/*
+ * Depth-first search, keeping proof and disproof numbers in a table
+ */
+
+type pnEntry struct {
+	hash   uint64
+	pn, dn int32
+}
+
+// size of a pnEntry in bytes
+const pnEntrySize = 16
+
+type pnTable struct {
+	entries []pnEntry
+	mask    uint64
+}
+
+func newPNTable(budget int) *pnTable {
+	size := 1
+	for size*2*pnEntrySize <= budget {
+		size *= 2
+	}
+	return &pnTable{
+		entries: make([]pnEntry, size),
+		mask:    uint64(size - 1),
+	}
+}
+
+func (t *pnTable) lookup(hash uint64) (pn, dn int, ok bool) {
+	entry := &t.entries[hash&t.mask]
+	if entry.hash != hash {
+		return 1, 1, false
+	}
+	return int(entry.pn), int(entry.dn), true
+}
+
+func (t *pnTable) store(hash uint64, pn, dn int) {
+	t.entries[hash&t.mask] = pnEntry{hash: hash, pn: int32(pn), dn: int32(dn)}
+}
+
+// childNumbers returns the proof and disproof numbers of the position
+// after making move m, from the table if they're in it.
+func (p *PNS) childNumbers(m int, or bool) (pn, dn int) {
+	p.pos.Make(m)
+	hash, _ := p.pos.CanonicalHash()
+	pn, dn, ok := p.table.lookup(hash)
+	if !ok {
+		result, _ := pnMoves(&p.pos)
+		pn, dn = numbers(result, or)
+	}
+	p.pos.Unmake()
+	return pn, dn
+}
+
+func (p *PNS) depthFirstSearch() (move int, proofSize int, proved bool) {
+	result, moves := pnMoves(&p.pos)
+	if result != unknownResult {
+		return p.unsearched(result)
+	}
+
+	// Entries from earlier moves' searches still hold, and
+	// might save searching some positions over again.
+	pn, _ := p.mid(true, infinity-1, infinity-1)
+
+	// The move closest to a proof, or a proof
+	move = moves[0]
+	best := infinity + 1
+	for _, m := range moves {
+		if cpn, _ := p.childNumbers(m, false); cpn < best {
+			move, best = m, cpn
+		}
+	}
+	if pn != 0 || best != 0 {
+		// No proof, or the table lost the proved move
+		return move, 0, false
+	}
+
+	p.pos.Make(move)
+	proofSize = p.tableProofSize(false, make(map[uint64]int))
+	p.pos.Unmake()
+	return move, proofSize, true
+}
+
+// mid searches p.pos until its proof number reaches pnLimit or its
+// disproof number reaches dnLimit, or the node budget runs out,
+// and returns its proof and disproof numbers.
+func (p *PNS) mid(or bool, pnLimit, dnLimit int) (pn, dn int) {
+	p.nodeCount++
+	hash, _ := p.pos.CanonicalHash()
+
+	result, moves := pnMoves(&p.pos)
+	if result != unknownResult {
+		pn, dn = numbers(result, or)
+		p.table.store(hash, pn, dn)
+		return pn, dn
+	}
+
+	for {
+		// Collect children's numbers, and pick the most proving
+		best, second := -1, infinity
+		var bestPN, bestDN int
+		if or {
+			pn, dn = infinity, 0
+		} else {
+			pn, dn = 0, infinity
+		}
+		for _, m := range moves {
+			cpn, cdn := p.childNumbers(m, !or)
+			if or {
+				dn = addNumbers(dn, cdn)
+				if cpn < pn {
+					pn = cpn
+				}
+				if best < 0 || cpn < bestPN {
+					if best >= 0 {
+						second = bestPN
+					}
+					best, bestPN, bestDN = m, cpn, cdn
+				} else if cpn < second {
+					second = cpn
+				}
+			} else {
+				pn = addNumbers(pn, cpn)
+				if cdn < dn {
+					dn = cdn
+				}
+				if best < 0 || cdn < bestDN {
+					if best >= 0 {
+						second = bestDN
+					}
+					best, bestPN, bestDN = m, cpn, cdn
+				} else if cdn < second {
+					second = cdn
+				}
+			}
+		}
+
+		if pn >= pnLimit || dn >= dnLimit || p.outOfNodes() {
+			p.table.store(hash, pn, dn)
+			return pn, dn
+		}
+
+		// Thresholds for the child: stop when it's no longer the
+		// most proving, or when this node would reach its limits.
+		var childPN, childDN int
+		if or {
+			childPN = min(pnLimit, addNumbers(second, 1))
+			childDN = dnLimit - dn + bestDN
+		} else {
+			childPN = pnLimit - pn + bestPN
+			childDN = min(dnLimit, addNumbers(second, 1))
+		}
+
+		p.pos.Make(best)
+		p.mid(!or, childPN, childDN)
+		p.pos.Unmake()
+	}
+}
+
+// tableProofSize counts the leaves of the proof tree under p.pos,
+// going by the table. Positions the table lost count as leaves.
+func (p *PNS) tableProofSize(or bool, sizes map[uint64]int) int {
+	hash, _ := p.pos.CanonicalHash()
+	if size, ok := sizes[hash]; ok {
+		return size
+	}
+
+	result, moves := pnMoves(&p.pos)
+	size := 1
+	if result == unknownResult {
+		size = 0
+		for _, m := range moves {
+			pn, _ := p.childNumbers(m, !or)
+			if or && pn != 0 {
+				continue
+			}
+			p.pos.Make(m)
+			size += p.tableProofSize(!or, sizes)
+			p.pos.Unmake()
+			if or {
+				break
+			}
+		}
+		if size == 0 {
+			size = 1
+		}
+	}
+
+	sizes[hash] = size
+	return size
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,438 @@
+package players
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"squava2/solution"
+)
+
+/*
+ * Players by specification string: a player type, optionally
+ * followed by a colon and comma-separated options, each a name,
+ * or a name=value pair:
+ *
+ *   mcts:ucb1,iters=200000,c=1.1,threads=8
+ *   ab:eval=avoid,depth=10,tt=64MB
+ *
+ * The single letters the commands have always used, A, G, M, U, R,
+ * N, D and P, are aliases for specs, and can have options too: U:iters=1000
+ */
+
+// Env holds what players might need from the command making them.
+type Env struct {
+	DB            *solution.DB        // perfect players look moves up in it
+	Tablebase     *solution.Tablebase // alpha/beta and MCTS players look endgames up in it
+	Deterministic bool
+
+	// Defaults holds options, in spec form, that players get unless
+	// their specs say otherwise. Player types that don't take an
+	// option in Defaults ignore it.
+	Defaults string
+}
+
+// Factory makes a player of some type from its options.
+type Factory func(opts *Options, env Env) (Player, error)
+
+type registration struct {
+	factory Factory
+	usage   string
+}
+
+var registry = map[string]registration{}
+
+// aliases are the single-letter player types
+var aliases = map[string]string{
+	"A": "ab",
+	"G": "ab:eval=avoid",
+	"M": "mcts",
+	"U": "mcts:ucb1",
+	"R": "mcts:rave",
+	"N": "pns",
+	"D": "pns:df",
+	"P": "perfect",
+}
+
+// Register makes player type name available to NewPlayer.
+// Usage describes the type's options.
+func Register(name string, usage string, factory Factory) {
+	registry[name] = registration{factory: factory, usage: usage}
+}
+
+func init() {
+	Register("ab", "alpha/beta minimax: eval=EVALUATOR, depth=N (0: by move number), tt=SIZE, time=DURATION per move, clock=DURATION per game, threads=N, order=full|plain, pvs, aspiration=WIDTH, pv, search=alphabeta|mtdf, det", newAlphaBetaPlayer)
+	Register("mcts", "Monte Carlo tree search: plain|ucb1|rave, iters=N (0: until out of time), c=EXPLORATION, k=RAVE EQUIVALENCE, threads=N, parallel=root|tree, playout=light|heavy|evaluator, eval=EVALUATOR (default avoid), final=visits|ratio|robust", newMCTSPlayer)
+	Register("pns", "proof-number search: df (PN*), budget=NODES, table=SIZE (PN*), fallback=ab|none|SPEC (options joined by +), and ab options for fallback=ab", newPNSPlayer)
+	Register("perfect", "perfect play from a solution database: det", newPerfectPlayer)
+}
+
+// Usage describes the player types, aliases and their options.
+func Usage() string {
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, registry[name].usage)
+	}
+	var letters []string
+	for letter := range aliases {
+		letters = append(letters, letter)
+	}
+	sort.Strings(letters)
+	for _, letter := range letters {
+		fmt.Fprintf(&b, "%s = %s\n", letter, aliases[letter])
+	}
+	b.WriteString("Evaluators:\n")
+	for _, name := range EvaluatorNames() {
+		fmt.Fprintf(&b, "%s: %s\n", name, evaluators[name].usage)
+	}
+	return b.String()
+}
+
+// Kind returns the player type spec names, aliases resolved.
+func Kind(spec string) (string, error) {
+	kind, _, err := parseSpec(spec)
+	return kind, err
+}
+
+// NewPlayer makes the player spec describes.
+func NewPlayer(spec string, env Env) (Player, error) {
+	kind, specOpts, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	reg, ok := registry[kind]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown player type %s", spec, kind)
+	}
+
+	opts := &Options{values: map[string]string{}, used: map[string]bool{}}
+	if env.Defaults != "" {
+		defaults, err := parseOptions(env.Defaults)
+		if err != nil {
+			return nil, fmt.Errorf("default options: %w", err)
+		}
+		for name, value := range defaults {
+			opts.values[name] = value
+		}
+	}
+	for name, value := range specOpts {
+		opts.values[name] = value
+	}
+
+	player, err := reg.factory(opts, env)
+	if err == nil {
+		err = opts.err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", spec, err)
+	}
+
+	// Defaults can hold options for other types, but not specs
+	var unknown []string
+	for name := range specOpts {
+		if !opts.used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%s: unknown %s option %s", spec, kind, strings.Join(unknown, ", "))
+	}
+
+	return player, nil
+}
+
+// parseSpec splits spec into player type and options,
+// replacing a single-letter alias with its spec.
+func parseSpec(spec string) (kind string, opts map[string]string, err error) {
+	kind, options, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	if kind == "" {
+		return "", nil, fmt.Errorf("player spec %q has no player type", spec)
+	}
+
+	if alias, ok := aliases[strings.ToUpper(kind)]; ok {
+		kind, aliasOptions, _ := strings.Cut(alias, ":")
+		if options != "" && aliasOptions != "" {
+			options = aliasOptions + "," + options
+		} else if aliasOptions != "" {
+			options = aliasOptions
+		}
+		opts, err = parseOptions(options)
+		return kind, opts, err
+	}
+
+	opts, err = parseOptions(options)
+	return strings.ToLower(kind), opts, err
+}
+
+// parseOptions turns "name,name=value,..." into a map. Names
+// without values get value "", later options replace earlier ones.
+func parseOptions(options string) (map[string]string, error) {
+	opts := map[string]string{}
+	if strings.TrimSpace(options) == "" {
+		return opts, nil
+	}
+	for _, option := range strings.Split(options, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		if name == "" {
+			return nil, fmt.Errorf("empty option in %q", options)
+		}
+		opts[strings.ToLower(name)] = value
+	}
+	return opts, nil
+}
+
+// Options are a player spec's options, with methods that get them
+// as the types factories need. The first bad value makes an error
+// that NewPlayer returns, so factories needn't check each one.
+type Options struct {
+	values map[string]string
+	used   map[string]bool
+	err    error
+}
+
+// Has reports whether option name got set.
+func (o *Options) Has(name string) bool {
+	_, ok := o.values[name]
+	return ok
+}
+
+func (o *Options) lookup(name string) (string, bool) {
+	o.used[name] = true
+	value, ok := o.values[name]
+	return value, ok
+}
+
+func (o *Options) fail(name, value string, err error) {
+	if o.err == nil {
+		o.err = fmt.Errorf("option %s=%s: %v", name, value, err)
+	}
+}
+
+// Flag is true if option name is there without a value, or is true.
+func (o *Options) Flag(name string) bool {
+	value, ok := o.lookup(name)
+	if !ok {
+		return false
+	}
+	if value == "" {
+		return true
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		o.fail(name, value, err)
+	}
+	return b
+}
+
+// Int returns option name's integer value, def if not set.
+func (o *Options) Int(name string, def int) int {
+	value, ok := o.lookup(name)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		o.fail(name, value, fmt.Errorf("not a whole number"))
+		return def
+	}
+	return n
+}
+
+// Float returns option name's value, def if not set.
+func (o *Options) Float(name string, def float64) float64 {
+	value, ok := o.lookup(name)
+	if !ok {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		o.fail(name, value, fmt.Errorf("not a number"))
+		return def
+	}
+	return f
+}
+
+// Text returns option name's value, def if not set.
+func (o *Options) Text(name string, def string) string {
+	value, ok := o.lookup(name)
+	if !ok {
+		return def
+	}
+	return value
+}
+
+// Duration returns option name's value, like 500ms or 2s, def if not set.
+func (o *Options) Duration(name string, def time.Duration) time.Duration {
+	value, ok := o.lookup(name)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		o.fail(name, value, fmt.Errorf("not a duration"))
+		return def
+	}
+	return d
+}
+
+// Size returns option name's value in bytes, def if not set.
+// Values can have a KB, MB or GB suffix, powers of 1024.
+func (o *Options) Size(name string, def int) int {
+	value, ok := o.lookup(name)
+	if !ok {
+		return def
+	}
+	number, unit := strings.ToUpper(value), 1
+	for suffix, size := range map[string]int{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if strings.HasSuffix(number, suffix) {
+			number, unit = strings.TrimSuffix(number, suffix), size
+			break
+		}
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 {
+		o.fail(name, value, fmt.Errorf("not a size, like 64MB"))
+		return def
+	}
+	return n * unit
+}
+
+// Choice returns option name's value, which has to be one
+// of choices, def if not set.
+func (o *Options) Choice(name string, def string, choices ...string) string {
+	value, ok := o.lookup(name)
+	if !ok {
+		return def
+	}
+	for _, choice := range choices {
+		if value == choice {
+			return value
+		}
+	}
+	o.fail(name, value, fmt.Errorf("not one of %s", strings.Join(choices, ", ")))
+	return def
+}
+
+// OneOf returns whichever of names is there as an option without
+// a value, def if none is. More than one is an error.
+func (o *Options) OneOf(def string, names ...string) string {
+	found := ""
+	for _, name := range names {
+		if !o.Flag(name) {
+			continue
+		}
+		if found != "" && o.err == nil {
+			o.err = fmt.Errorf("options %s and %s conflict", found, name)
+		}
+		found = name
+	}
+	if found == "" {
+		return def
+	}
+	return found
+}
+
+func newAlphaBetaPlayer(opts *Options, env Env) (Player, error) {
+	return alphaBetaFromOptions(opts, env, "basic")
+}
+
+// alphaBetaFromOptions makes an AlphaBeta player, with static
+// valuation defaultEval unless opts say otherwise.
+func alphaBetaFromOptions(opts *Options, env Env, defaultEval string) (*AlphaBeta, error) {
+	deterministic := env.Deterministic || opts.Flag("det")
+	ab := NewAlphaBeta(deterministic, 10)
+	name, eval, err := evaluatorFromOptions(opts, defaultEval)
+	if err != nil {
+		return nil, err
+	}
+	ab.SetEvaluator(name, eval)
+	ab.SetDepth(opts.Int("depth", 0))
+	ab.SetTableSize(opts.Size("tt", DefaultTableSize))
+	ab.SetMoveTime(opts.Duration("time", 0))
+	ab.SetClock(opts.Duration("clock", 0))
+	if threads := opts.Int("threads", 0); threads > 0 {
+		ab.SetWorkers(threads)
+	}
+	ab.SetMoveOrdering(opts.Choice("order", "full", "full", "plain") == "full")
+	ab.SetPVS(opts.Flag("pvs"))
+	ab.SetAspiration(opts.Int("aspiration", 0))
+	ab.SetRecordPV(opts.Flag("pv"))
+	ab.SetMTDF(opts.Choice("search", "alphabeta", "alphabeta", "mtdf") == "mtdf")
+	ab.SetTablebase(env.Tablebase)
+	return ab, nil
+}
+
+func newMCTSPlayer(opts *Options, env Env) (Player, error) {
+	mcts := NewMCTS(opts.Int("iters", DefaultIterations))
+	mcts.SetExploration(opts.Float("c", DefaultExploration))
+	switch opts.OneOf("plain", "plain", "ucb1", "rave") {
+	case "ucb1":
+		mcts.SetUCB1()
+	case "rave":
+		mcts.SetRAVE(opts.Float("k", DefaultRAVEEquivalence))
+	}
+
+	parallel := RootParallel
+	if opts.Choice("parallel", "root", "root", "tree") == "tree" {
+		parallel = TreeParallel
+	}
+	mcts.SetWorkers(opts.Int("threads", 0), parallel)
+
+	policy, _ := PlayoutPolicy(opts.Choice("playout", "heavy", "light", "heavy", "evaluator"))
+	mcts.SetPlayout(policy)
+	_, eval, err := evaluatorFromOptions(opts, "avoid")
+	if err != nil {
+		return nil, err
+	}
+	mcts.SetEvaluator(eval)
+	selection, _ := FinalSelection(opts.Choice("final", "visits", "visits", "ratio", "robust"))
+	mcts.SetFinalSelection(selection)
+
+	mcts.SetTablebase(env.Tablebase)
+	return mcts, nil
+}
+
+// newPNSPlayer makes a proof-number search player. Its fallback is
+// A/B+avoid with ab options from the PNS player's own spec, or none,
+// or any player spec, with + between its options, since commas
+// separate the PNS player's: fallback=mcts:ucb1+iters=100000
+func newPNSPlayer(opts *Options, env Env) (Player, error) {
+	var fallback Player
+	switch spec := opts.Text("fallback", "ab"); spec {
+	case "none":
+	case "ab":
+		ab, err := alphaBetaFromOptions(opts, env, "avoid")
+		if err != nil {
+			return nil, err
+		}
+		fallback = ab
+	default:
+		player, err := NewPlayer(strings.ReplaceAll(spec, "+", ","), env)
+		if err != nil {
+			return nil, fmt.Errorf("fallback: %w", err)
+		}
+		fallback = player
+	}
+	pns := NewPNS(fallback)
+	if opts.Flag("df") {
+		pns.SetDepthFirst(opts.Size("table", DefaultTableSize))
+	}
+	pns.SetNodeBudget(opts.Int("budget", DefaultProofNodes))
+	return pns, nil
+}
+
+func newPerfectPlayer(opts *Options, env Env) (Player, error) {
+	if env.DB == nil {
+		return nil, fmt.Errorf("perfect player needs a solution database")
+	}
+	return NewPerfect(env.DB, env.Deterministic || opts.Flag("det")), nil
+}
@@ -0,0 +1,66 @@
+package players
+
+import (
+	"testing"
+
+	"squava2/game"
+)
+
+func TestPNSFallbackSpec(t *testing.T) {
+	player, err := NewPlayer("pns:budget=1000,fallback=mcts:ucb1+iters=200", Env{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pns := player.(*PNS)
+	mcts, ok := pns.fallback.(*MCTS)
+	if !ok {
+		t.Fatalf("fallback is a %T, want *MCTS", pns.fallback)
+	}
+	if mcts.Name() != "MCTS/UCB1" || mcts.iterations != 200 {
+		t.Errorf("fallback is %s with %d iterations, want MCTS/UCB1 with 200", mcts.Name(), mcts.iterations)
+	}
+
+	// Nothing to prove two moves in, so the fallback chooses
+	pns.MakeMove(2, 2, MAXIMIZER)
+	pns.MakeMove(1, 1, MINIMIZER)
+	x, y, _, _ := pns.ChooseMove()
+	if cell := game.Cell(x, y); x < 0 || x > 4 || y < 0 || y > 4 || cell == game.Cell(2, 2) || cell == game.Cell(1, 1) {
+		t.Errorf("fallback chose <%d,%d>, not an empty cell", x, y)
+	}
+}
+
+func TestPNSFallbackAB(t *testing.T) {
+	player, err := NewPlayer("pns:fallback=ab,depth=4", Env{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ab, ok := player.(*PNS).fallback.(*AlphaBeta)
+	if !ok {
+		t.Fatalf("fallback is a %T, want *AlphaBeta", player.(*PNS).fallback)
+	}
+	if ab.fixedDepth != 4 || ab.Name() != "A/B+Avoid" {
+		t.Errorf("fallback is %s with depth %d, want A/B+Avoid with 4", ab.Name(), ab.fixedDepth)
+	}
+}
+
+func TestPNSFallbackErrors(t *testing.T) {
+	for _, spec := range []string{
+		"pns:fallback=nope",
+		"pns:fallback=mcts+bogus=1",
+		"pns:fallback=mcts,depth=4", // ab options only go to fallback=ab
+	} {
+		if _, err := NewPlayer(spec, Env{}); err == nil {
+			t.Errorf("NewPlayer(%q) made a player, want an error", spec)
+		}
+	}
+}
+
+func TestPNSFallbackNone(t *testing.T) {
+	player, err := NewPlayer("pns:fallback=none", Env{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fallback := player.(*PNS).fallback; fallback != nil {
+		t.Errorf("fallback is a %T, want none", fallback)
+	}
+}
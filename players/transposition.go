@@ -0,0 +1,144 @@
+package players
+
+import "sync/atomic"
+
+// Fixed-size transposition table, indexed by Zobrist hash.
+// A slot keeps the entry from the deepest search of the position
+// that hashes to it, unless that entry is left over from
+// a previous ChooseMove.
+//
+// Parallel searches share a table without locking it. A slot holds
+// an entry packed into one word, and the entry's hash XOR that word
+// in another, each read and written atomically. A slot one search
+// is writing while another reads it doesn't match the hash.
+
+// Kinds of value an entry holds
+const (
+	exactValue = iota
+	lowerBound // search failed high, value is at least this
+	upperBound // search failed low, value is at most this
+)
+
+type tableEntry struct {
+	hash       uint64
+	value      int32
+	depth      int8 // plies searched below the position
+	kind       uint8
+	move       int8 // best move found, -1 if none
+	generation uint8
+}
+
+type tableSlot struct {
+	check uint64 // hash ^ data
+	data  uint64 // packed tableEntry, all but the hash
+}
+
+// size of a tableSlot in bytes
+const tableEntrySize = 16
+
+type transTable struct {
+	entries    []tableSlot
+	mask       uint64
+	generation uint8
+}
+
+// newTransTable makes a table that fits in budget bytes.
+// Returns nil for budgets too small to hold any entries.
+func newTransTable(budget int) *transTable {
+	count := budget / tableEntrySize
+	if count < 1 {
+		return nil
+	}
+	// Round down to a power of 2, so hash & mask is an index
+	size := 1
+	for size*2 <= count {
+		size *= 2
+	}
+	return &transTable{
+		entries: make([]tableSlot, size),
+		mask:    uint64(size - 1),
+	}
+}
+
+// newSearch marks all existing entries as out of date,
+// so they get replaced no matter their depth.
+func (t *transTable) newSearch() {
+	t.generation++
+}
+
+func (e tableEntry) pack() uint64 {
+	return uint64(uint32(e.value)) |
+		uint64(uint8(e.depth))<<32 |
+		uint64(e.kind)<<40 |
+		uint64(uint8(e.move))<<48 |
+		uint64(e.generation)<<56
+}
+
+func unpack(hash uint64, data uint64) tableEntry {
+	return tableEntry{
+		hash:       hash,
+		value:      int32(uint32(data)),
+		depth:      int8(data >> 32),
+		kind:       uint8(data >> 40),
+		move:       int8(data >> 48),
+		generation: uint8(data >> 56),
+	}
+}
+
+// load reads the entry in slot
+func (slot *tableSlot) load() tableEntry {
+	data := atomic.LoadUint64(&slot.data)
+	return unpack(atomic.LoadUint64(&slot.check)^data, data)
+}
+
+// probe finds the entry for hash, if there is one.
+func (t *transTable) probe(hash uint64) (tableEntry, bool) {
+	entry := t.entries[hash&t.mask].load()
+	if entry.hash != hash || entry.depth == 0 {
+		return tableEntry{}, false
+	}
+	return entry, true
+}
+
+// store saves a search result. Replaces whatever the slot holds
+// unless that's from this search, from deeper, and for a different position.
+func (t *transTable) store(hash uint64, depth int, kind uint8, value int, move int) {
+	slot := &t.entries[hash&t.mask]
+	if old := slot.load(); old.generation == t.generation && old.hash != hash && int(old.depth) > depth {
+		return
+	}
+	data := tableEntry{
+		value:      int32(value),
+		depth:      int8(depth),
+		kind:       kind,
+		move:       int8(move),
+		generation: t.generation,
+	}.pack()
+	atomic.StoreUint64(&slot.data, data)
+	atomic.StoreUint64(&slot.check, hash^data)
+}
+
+// Win and loss values depend on the ply at which the win or
+// loss happens. Table entries hold win and loss values relative
+// to the position, so they're correct at whatever ply the
+// position gets reached.
+
+func valueToTable(value int, ply int) int {
+	switch {
+	case value > WIN/2:
+		return value + ply
+	case value < LOSS/2:
+		return value - ply
+	}
+	return value
+}
+
+func valueFromTable(value int, ply int) int {
+	switch {
+	case value > WIN/2:
+		return value - ply
+	case value < LOSS/2:
+		return value + ply
+	}
+	return value
+}
@@ -0,0 +1,56 @@
+package players
+
+import "testing"
+
+// TestTableRoundTrip stores entries with every field at its limits,
+// and checks that probing the table gets them back unchanged.
+func TestTableRoundTrip(t *testing.T) {
+	// Room for each generation's entries in slots of their own
+	table := newTransTable(256 * tableEntrySize)
+	for _, generation := range []int{0, 1, 255} {
+		for table.generation != uint8(generation) {
+			table.newSearch()
+		}
+		hash := uint64(0xfedcba98) << 32
+		for _, kind := range []uint8{exactValue, lowerBound, upperBound} {
+			for _, depth := range []int{1, 25, 127} {
+				for _, move := range []int{-1, 0, 24} {
+					for _, value := range []int{0, 1, -1, 9999, -9999, WIN, LOSS, 2 * WIN, 2 * LOSS} {
+						hash++
+						table.store(hash, depth, kind, value, move)
+						entry, ok := table.probe(hash)
+						want := tableEntry{hash, int32(value), int8(depth), kind, int8(move), uint8(generation)}
+						if !ok || entry != want {
+							t.Fatalf("stored %+v, probe found %+v, %v", want, entry, ok)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestTableValues checks that wins and losses found some plies
+// below a position stored at one ply come back as wins and losses
+// as many plies below it reached at any other ply, and that other
+// values come back unchanged.
+func TestTableValues(t *testing.T) {
+	for _, ply := range []int{1, 2, 12, 25} {
+		for _, at := range []int{1, 2, 12, 25} {
+			for _, below := range []int{0, 1, 24} {
+				cases := [][2]int{
+					{WIN - ply - below, WIN - at - below},
+					{LOSS + ply + below, LOSS + at + below},
+				}
+				for _, value := range []int{0, 1, -1, WIN / 2, LOSS / 2, 1234, -1234} {
+					cases = append(cases, [2]int{value, value})
+				}
+				for _, c := range cases {
+					if got := valueFromTable(valueToTable(c[0], ply), at); got != c[1] {
+						t.Errorf("%d stored at ply %d is %d at ply %d, want %d", c[0], ply, got, at, c[1])
+					}
+				}
+			}
+		}
+	}
+}
@@ -1,24 +1,63 @@
 package players
 
+import (
+	"context"
+
+	"squava2/game"
+)
+
 // Player interface describes something that has an internal representation of
 // a squava game and can choose a move based on that internal representation.
 // The "board" type isn't specified externally, but that means that each implementation
 // of Player has to have its own way to find a "winner" (loser), and to make a human
 // readable representation of its internal board state.
 // MakeMove has player type so that a driver program can set a board to some desired
-// config before letting the Player choose a move.
+// config before letting the Player choose a move. A Player's own marks are MAXIMIZER's.
+// UnmakeMove takes back the last move on the board, whichever player made it,
+// and Reset empties the board for a new game, so drivers can reuse a Player.
+// SetPosition copies a board, a player's own marks MAXIMIZER's, and the side to move next.
+// ChooseMoveContext is ChooseMove, but stops searching when ctx is done, making the
+// best move found so far, and reports whether the search finished.
+// Options particular to an implementation come from player spec strings, see NewPlayer.
 type Player interface {
 	Name() string
 	MakeMove(int, int, int)           // x,y coords, type of player (MAXIMIZER, MINIMIZER)
 	ChooseMove() (int, int, int, int) // x,y coords of move, value, leaf node count
+	ChooseMoveContext(context.Context) (int, int, int, int, bool)
 	FindWinner() int
 	String() string // human readable formatted board
-	// Options(...string) // name=value pairs particular to an implementation
+	UnmakeMove()
+	Reset()
+	SetPosition(*game.Position, int) // board, side to move next
+}
+
+// Ponderer is a Player that can think on its opponent's time.
+// After the Player's own move, Ponder starts a search in the
+// background, and StopPondering stops it, before the opponent's
+// move gets made with MakeMove. Any other method stops it too.
+// PonderStats counts the opponent moves the Player pondered
+// on, hits, and the ones it didn't, misses.
+type Ponderer interface {
+	Player
+	Ponder()
+	StopPondering()
+	PonderStats() (hits, misses int)
+}
+
+// isDone is true if done is closed. A nil done never is.
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
 }
 
-// Manifest constants to improve understanding
+// Manifest constants to improve understanding,
+// the same values package game uses.
 const (
-	MAXIMIZER = 1
-	MINIMIZER = -1
-	UNSET     = 0
+	MAXIMIZER = game.MAXIMIZER
+	MINIMIZER = game.MINIMIZER
+	UNSET     = game.UNSET
 )
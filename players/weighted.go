@@ -0,0 +1,186 @@
+package players
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"os"
+
+	"squava2/game"
+)
+
+/*
+ * A static evaluator that adds up features of a move, each times
+ * a weight, with the weights in a JSON file, so that sets of weights
+ * can be tuned, and compared, without changing any code:
+ *
+ *   ab:eval=weighted,weights=weights/lines.json
+ *
+ * A file only has to have the weights it changes from the defaults,
+ * which make the same evaluation as avoid.
+ */
+
+// Weights are the weights of the features a weighted Evaluator
+// adds up. Each feature counts something about the move just made,
+// for whoever made it.
+type Weights struct {
+	// Open weighs the open 4 in a rows through the move, ones
+	// the opponent has no marks in, by the mover's marks in them,
+	// 1, 2 or 3.
+	Open [3]int `json:"open"`
+	// Blocked weighs the 4 in a rows the move blocks, ones that
+	// were open for the opponent, by the opponent's marks in them.
+	Blocked [3]int `json:"blocked"`
+	// TwoGap weighs the 3 in a rows through the move with 2 of the
+	// mover's marks and an empty cell, which the mover can't mark
+	// without losing, unless it makes 4 in a row.
+	TwoGap int `json:"two_gap"`
+	// No2 and NoMiddle2 weigh avoid's patterns: 2 of the 3 in a
+	// rows from an edge's middle cell to the next edge, and the
+	// middle 2 of a diagonal 4 in a row that nobody's ahead in.
+	No2       int `json:"no2"`
+	NoMiddle2 int `json:"no_middle2"`
+	// Center weighs the 4 in a rows through the move's cell,
+	// more for cells nearer the center.
+	Center int `json:"center"`
+	// Cells weighs each cell, like the scores SetScores sets.
+	Cells [25]int `json:"cells"`
+	// Mobility weighs the empty cells the mover can mark without
+	// losing, less those the opponent can, after the move.
+	Mobility int `json:"mobility"`
+}
+
+// DefaultWeights value moves the way the avoid Evaluator does.
+var DefaultWeights = Weights{
+	Open:      [3]int{0, 0, 30},
+	No2:       -100,
+	NoMiddle2: -100,
+}
+
+// LoadWeights reads Weights from JSON file fileName. Weights
+// the file doesn't have are DefaultWeights, names it has that
+// aren't weights, and anything after the weights, are errors.
+func LoadWeights(fileName string) (Weights, error) {
+	weights := DefaultWeights
+	buf, err := os.ReadFile(fileName)
+	if err != nil {
+		return weights, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&weights); err != nil {
+		return weights, fmt.Errorf("weights file %s: %w", fileName, err)
+	}
+	if decoder.More() {
+		return weights, fmt.Errorf("weights file %s: more after the weights", fileName)
+	}
+	return weights, nil
+}
+
+// WeightedEvaluator is an Evaluator that values moves
+// by their features, times Weights.
+type WeightedEvaluator struct {
+	Weights Weights
+}
+
+func newWeightedEvaluator(opts *Options) (Evaluator, error) {
+	weights := DefaultWeights
+	if fileName := opts.Text("weights", ""); fileName != "" {
+		var err error
+		if weights, err = LoadWeights(fileName); err != nil {
+			return nil, err
+		}
+	}
+	return &WeightedEvaluator{Weights: weights}, nil
+}
+
+// Value adds up the features of the move at cell, times their
+// weights, after checking whether it wins or loses.
+func (e *WeightedEvaluator) Value(pos *game.Position, ply int, cell int) (final bool, value int) {
+	w := &e.Weights
+	player := pos.At(cell)
+	mine, theirs := pos.Marks(player), pos.Marks(-player)
+	bit := uint32(1) << cell
+
+	for _, m := range game.QuadMasksAt[cell] {
+		if mine&m == m {
+			return true, player * (WIN - ply)
+		}
+	}
+	for _, m := range game.TripletMasksAt[cell] {
+		if mine&m == m {
+			return true, player * (LOSS + ply)
+		}
+	}
+
+	for _, m := range game.QuadMasksAt[cell] {
+		switch {
+		case theirs&m == 0:
+			value += w.Open[bits.OnesCount32(mine&m)-1]
+		case mine&m == bit:
+			value += w.Blocked[bits.OnesCount32(theirs&m)-1]
+		}
+	}
+
+	if w.TwoGap != 0 {
+		empty := pos.Empty()
+		for _, m := range game.TripletMasksAt[cell] {
+			if bits.OnesCount32(mine&m) == 2 && empty&m != 0 {
+				value += w.TwoGap
+			}
+		}
+	}
+
+	for _, m := range no2 {
+		if m&bit != 0 && theirs&m == 0 && bits.OnesCount32(mine&m) == 2 {
+			value += w.No2
+		}
+	}
+	for _, quad := range noMiddle2 {
+		if quad.middle&bit != 0 && mine&quad.middle == quad.middle &&
+			bits.OnesCount32(mine&quad.ends) == bits.OnesCount32(theirs&quad.ends) {
+			value += w.NoMiddle2
+		}
+	}
+
+	value += w.Center*len(game.QuadMasksAt[cell]) + w.Cells[cell]
+
+	if w.Mobility != 0 {
+		empty := pos.Empty()
+		value += w.Mobility * (safeCells(mine, empty) - safeCells(theirs, empty))
+	}
+
+	return false, player * value
+}
+
+// safeCells counts the cells of empty that whoever has marks
+// can mark without losing: 4 in a row, or no 3 in a row.
+func safeCells(marks uint32, empty uint32) int {
+	n := 0
+	for empty != 0 {
+		cell := bits.TrailingZeros32(empty)
+		empty &^= 1 << cell
+		if !losingCell(marks, cell) {
+			n++
+		}
+	}
+	return n
+}
+
+// losingCell is true if marking cell, with marks
+// already, makes 3 in a row but not 4.
+func losingCell(marks uint32, cell int) bool {
+	marks |= 1 << cell
+	for _, m := range game.QuadMasksAt[cell] {
+		if marks&m == m {
+			return false
+		}
+	}
+	for _, m := range game.TripletMasksAt[cell] {
+		if marks&m == m {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package players
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestAvoidWeights checks that weights/avoid.json values every
+// move in benchPosition, and every reply to it, the way the avoid
+// Evaluator does.
+func TestAvoidWeights(t *testing.T) {
+	weights, err := LoadWeights(filepath.Join("..", "weights", "avoid.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weights != DefaultWeights {
+		t.Errorf("weights/avoid.json has %+v, the defaults are %+v", weights, DefaultWeights)
+	}
+	weighted := &WeightedEvaluator{Weights: weights}
+	avoid, err := NewEvaluator("avoid", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cell := range benchPosition().EmptyCells() {
+		pos := benchPosition()
+		pos.Make(cell)
+		final, value := weighted.Value(pos, 1, cell)
+		wantFinal, want := avoid.Value(pos, 1, cell)
+		if final != wantFinal || value != want {
+			t.Errorf("move %d: weighted %v, %d, avoid %v, %d", cell, final, value, wantFinal, want)
+		}
+		if final {
+			continue
+		}
+		for _, reply := range pos.EmptyCells() {
+			pos.Make(reply)
+			final, value := weighted.Value(pos, 2, reply)
+			wantFinal, want := avoid.Value(pos, 2, reply)
+			if final != wantFinal || value != want {
+				t.Errorf("move %d, reply %d: weighted %v, %d, avoid %v, %d", cell, reply, final, value, wantFinal, want)
+			}
+			pos.Unmake()
+		}
+	}
+}
+
+func TestLoadWeightsErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		"typo.json":     `{"no_2": -50}`,
+		"trailing.json": `{"no2": -50} {"center": 1}`,
+		"wrong.json":    `{"open": 30}`,
+	} {
+		fileName := filepath.Join(dir, name)
+		if err := os.WriteFile(fileName, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadWeights(fileName); err == nil {
+			t.Errorf("LoadWeights(%s) read %s, want an error", name, contents)
+		}
+	}
+
+	if _, err := LoadWeights(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadWeights of a missing file, want an error")
+	}
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"log"
 	"math/rand"
+	"os"
 	"strings"
 	"time"
 
+	"squava2/clock"
+	"squava2/game"
 	"squava2/players"
+	"squava2/solution"
 )
 
 const (
@@ -17,19 +23,71 @@ const (
 
 func main() {
 
-	maxDepthPtr := flag.Int("d", 10, "maximum lookahead depth (alpha/beta)")
+	maxDepthPtr := flag.Int("d", 0, "maximum lookahead depth, 0: by move number (alpha/beta)")
 	deterministic := flag.Bool("D", false, "Play deterministically")
-	firstType := flag.String("1", "A", "first player type, A: alphabeta, G: A/B+avoid bad positions, M: MCTS, U: MCTS+UCT")
-	secondType := flag.String("2", "M", "second player type, A: alphabeta, G: A/B+avoid bad positions, M: MCTS, U: MCTS+UCT")
+	firstType := flag.String("1", "A", "first player spec, like A, G, U, mcts:ucb1,iters=200000 or ab:eval=avoid,depth=10")
+	secondType := flag.String("2", "M", "second player spec")
 	nonInteractive := flag.Int("n", 1, "play <number> games non-interactively")
 	i1 := flag.Int("i1", 500000, "MCTS iterations, player 1")
 	i2 := flag.Int("i2", 500000, "MCTS iterations, player 2")
+	tableSize := flag.Int("T", players.DefaultTableSize>>20, "transposition table size, MB (alpha/beta)")
+	moveTime := flag.Duration("m", 0, "time per move, search deeper until it runs out (alpha/beta)")
+	gameTime := flag.Duration("c", 0, "time per game, search deeper until each move's share runs out (alpha/beta)")
+	dbName := flag.String("f", "squava.db", "solution database file (perfect)")
+	tbName := flag.String("b", "", "endgame tablebase file (alpha/beta, MCTS)")
+	evalName := flag.String("E", "", "evaluator for players that use one, unless their specs say, like basic, avoid or block (alpha/beta, MCTS evaluator playouts)")
+	workers := flag.Int("w", 0, "worker goroutines per search, 0 for a plain serial search (MCTS, alpha/beta)")
+	timeControl := flag.String("tc", "", "time control for both players, like 5m, 5m+2s or 2s/move, running out loses")
+	compareSpec := flag.String("C", "", "alpha/beta spec, like ab:search=mtdf, to compare with plain alpha/beta at each position of the game, by node count")
+	flag.Usage = usage
 	flag.Parse()
 
 	rand.Seed(time.Now().UTC().UnixNano())
 
+	tc, err := clock.Parse(*timeControl)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// With a clock, MCTS searches until its move's time runs out
+	if !tc.None() {
+		if !flagSet("i1") {
+			*i1 = 0
+		}
+		if !flagSet("i2") {
+			*i2 = 0
+		}
+	}
+
+	// Flags give players default options, their specs can override them
+	var envs [2]players.Env
+	for k, iterations := range []int{*i1, *i2} {
+		envs[k] = players.Env{
+			Deterministic: *deterministic,
+			Defaults: fmt.Sprintf("depth=%d,iters=%d,tt=%dMB,time=%v,clock=%v,threads=%d",
+				*maxDepthPtr, iterations, *tableSize, *moveTime, *gameTime, *workers) + evalOption(*evalName),
+		}
+	}
+
+	var db *solution.DB
+	for _, spec := range []string{*firstType, *secondType} {
+		if kind, err := players.Kind(spec); err == nil && kind == "perfect" && db == nil {
+			db = openDB(*dbName)
+		}
+	}
+
+	var tb *solution.Tablebase
+	if *tbName != "" {
+		tb = loadTablebase(*tbName)
+	}
+
+	for k := range envs {
+		envs[k].DB = db
+		envs[k].Tablebase = tb
+	}
+
 	if *nonInteractive > 1 {
-		nonInteractiveGames(*nonInteractive, *firstType, *secondType, *maxDepthPtr)
+		nonInteractiveGames(*nonInteractive, *firstType, *secondType, envs, tc)
 		return
 	}
 
@@ -37,76 +95,102 @@ func main() {
 
 	moveCounter := 0
 
-	first, second := createPlayers(*firstType,
-		*secondType, *maxDepthPtr, *deterministic)
+	first, second := createPlayers(*firstType, *secondType, envs)
+	clocks := [2]*clock.Clock{clock.New(tc), clock.New(tc)}
 
-	if *firstType == "M" || *firstType == "U" {
-		first.(*players.MCTS).SetIterations(*i1)
+	var cmp *comparison
+	if *compareSpec != "" {
+		cmp = newComparison(*compareSpec, envs[0])
 	}
 
-	if *secondType == "M" || *secondType == "U" {
-		second.(*players.MCTS).SetIterations(*i2)
-	}
+	// Referee's board: first is MAXIMIZER, second is MINIMIZER
+	bd := game.NewPosition(MAXIMIZER)
+
+	// Visits MCTS players' trees kept from move to move
+	var inherited [2]int
 
 	gameStart := time.Now()
 	for moveCounter < 25 {
 
-		before := time.Now()
-		i, j, value, leafCount := first.ChooseMove()
-		et := time.Since(before)
+		cmp.search(bd)
+		i, j, value, leafCount, et := timedMove(first, clocks[0], bd.MoveNumber())
+		inherited[0] += inheritedVisits(first)
 		second.MakeMove(i, j, MINIMIZER)
+		bd.MakeMove(game.Cell(i, j), MAXIMIZER)
 
 		moveCounter++
-		fmt.Printf("X (%s) <%d,%d> (%d) [%d] %v\n", first.Name(), i, j, value, leafCount, et)
+		fmt.Printf("X (%s) <%d,%d> (%d) [%d] %v%s\n", first.Name(), i, j, value, leafCount, et, clockReport(clocks[0]))
+		printPV(first)
 
-		winner = first.FindWinner() // main() thinks first is maximizer
+		winner = bd.Outcome()
+		if clocks[0].Flagged() {
+			winner = MINIMIZER
+			break
+		}
 		if winner != 0 || moveCounter >= 25 {
 			break
 		}
 
-		before = time.Now()
-		i, j, value, leafCount = second.ChooseMove()
-		et = time.Since(before)
+		cmp.search(bd)
+		i, j, value, leafCount, et = timedMove(second, clocks[1], bd.MoveNumber())
+		inherited[1] += inheritedVisits(second)
 		first.MakeMove(i, j, MINIMIZER)
+		bd.MakeMove(game.Cell(i, j), MINIMIZER)
 
 		moveCounter++
-		fmt.Printf("O (%s) <%d,%d> (%d) [%d] %v\n", second.Name(), i, j, value, leafCount, et)
+		fmt.Printf("O (%s) <%d,%d> (%d) [%d] %v%s\n", second.Name(), i, j, value, leafCount, et, clockReport(clocks[1]))
+		printPV(second)
 
-		fmt.Printf("%s\n", first)
+		fmt.Printf("%s\n", bd)
 
-		winner1 := first.FindWinner()
-		winner2 := -second.FindWinner() // main thinks second is minimizer
-		if winner1 != winner2 {
-			fmt.Printf("Winner disagreement. First %d, second %d\n", winner1, winner2)
+		winner = bd.Outcome()
+		if clocks[1].Flagged() {
+			winner = MAXIMIZER
+			break
 		}
-		if winner2 != 0 {
-			winner = winner2
+		if winner != 0 {
 			break
 		}
 
 	}
 	gameET := time.Since(gameStart)
 
+	for k, p := range []players.Player{first, second} {
+		if _, ok := p.(*players.MCTS); ok {
+			fmt.Printf("%c (%s) inherited %d visits from earlier moves\n", "XO"[k], p.Name(), inherited[k])
+		}
+	}
+
+	onTime := ""
+	if clocks[0].Flagged() || clocks[1].Flagged() {
+		onTime = " on time"
+	}
+
 	switch winner {
 	case 1:
-		fmt.Printf("player 1 X (%s) wins, %v\n", first.Name(), gameET)
+		fmt.Printf("player 1 X (%s) wins%s, %v\n", first.Name(), onTime, gameET)
 	case -1:
-		fmt.Printf("player 2 O (%s) wins, %v\n", second.Name(), gameET)
+		fmt.Printf("player 2 O (%s) wins%s, %v\n", second.Name(), onTime, gameET)
 	default:
 		fmt.Printf("Cat wins\n")
 	}
 
-	fmt.Printf("%s\n", first)
+	fmt.Printf("%s\n", bd)
 
+	cmp.report()
 }
 
-func nonInteractiveGames(gameCount int, firstType, secondType string, maxDepth int) {
+func nonInteractiveGames(gameCount int, firstType, secondType string, envs [2]players.Env, tc clock.Control) {
+
+	first, second := createPlayers(firstType, secondType, envs)
 
 	for i := 0; i < gameCount; i++ {
 
 		moveCounter := 0
 
-		first, second := createPlayers(firstType, secondType, maxDepth, false)
+		first.Reset()
+		second.Reset()
+		clocks := [2]*clock.Clock{clock.New(tc), clock.New(tc)}
 
 		fmt.Printf("%d\t%s\t%s\t", i, first.Name(), second.Name())
 
@@ -114,26 +198,38 @@ func nonInteractiveGames(gameCount int, firstType, secondType string, maxDepth i
 		var values [25][2]int
 		var winner int
 
+		bd := game.NewPosition(MAXIMIZER)
+
 		gameStart := time.Now()
 
 		for moveCounter < 25 {
 
-			i, j, value, _ := first.ChooseMove()
+			i, j, value, _, _ := timedMove(first, clocks[0], bd.MoveNumber())
 			moves[moveCounter][0], moves[moveCounter][1] = i, j
 			values[moveCounter][0] = value
 			second.MakeMove(i, j, MINIMIZER)
+			bd.MakeMove(game.Cell(i, j), MAXIMIZER)
 			moveCounter++
-			winner = first.FindWinner()
+			winner = bd.Outcome()
+			if clocks[0].Flagged() {
+				winner = MINIMIZER
+				break
+			}
 			if winner != 0 || moveCounter >= 25 {
 				break
 			}
 
-			i, j, value, _ = second.ChooseMove()
+			i, j, value, _, _ = timedMove(second, clocks[1], bd.MoveNumber())
 			moves[moveCounter][0], moves[moveCounter][1] = i, j
 			values[moveCounter][1] = value
 			first.MakeMove(i, j, MINIMIZER)
+			bd.MakeMove(game.Cell(i, j), MINIMIZER)
 			moveCounter++
-			winner = -second.FindWinner() // main thinks second is minimizer
+			winner = bd.Outcome()
+			if clocks[1].Flagged() {
+				winner = MAXIMIZER
+				break
+			}
 			if winner != 0 {
 				break
 			}
@@ -156,38 +252,181 @@ func nonInteractiveGames(gameCount int, firstType, secondType string, maxDepth i
 			fmt.Printf("%d%s,%d%s ", moves[i][0], marker[0], moves[i][1], marker[1])
 		}
 
+		if !tc.None() {
+			fmt.Printf("\t%v %v", clocks[0], clocks[1])
+			for k, c := range clocks {
+				if c.Flagged() {
+					fmt.Printf(" %c out of time", "XO"[k])
+				}
+			}
+		}
+
 		fmt.Printf("\n")
 	}
 }
 
-func createPlayers(firstType, secondType string, maxDepth int, deterministic bool) (players.Player, players.Player) {
+// createPlayers makes the players firstType and secondType
+// specs describe, exiting on bad specs.
+func createPlayers(firstType, secondType string, envs [2]players.Env) (players.Player, players.Player) {
+	first, err := players.NewPlayer(firstType, envs[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	second, err := players.NewPlayer(secondType, envs[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	return first, second
+}
 
-	firstType = strings.ToUpper(firstType)
-	secondType = strings.ToUpper(secondType)
+// timedMove has p choose a move, with c running, and with
+// the move's share of c's time to choose it in.
+func timedMove(p players.Player, c *clock.Clock, moveNumber int) (x, y, value, leafCount int, et time.Duration) {
+	ctx, cancel := c.Context(context.Background(), moveNumber)
+	defer cancel()
+	before := time.Now()
+	c.Start()
+	x, y, value, leafCount, _ = p.ChooseMoveContext(ctx)
+	c.Stop()
+	return x, y, value, leafCount, time.Since(before)
+}
+
+// clockReport formats the time left on c, for a move's line of output.
+func clockReport(c *clock.Clock) string {
+	if c.Control().None() {
+		return ""
+	}
+	return fmt.Sprintf(" clock %v", c)
+}
+
+// printPV prints the line of play p expects, if p is
+// alpha/beta with a spec that has the pv option.
+func printPV(p players.Player) {
+	if ab, ok := p.(*players.AlphaBeta); ok {
+		if pv := ab.PrincipalVariation(); len(pv) > 1 {
+			fmt.Printf("\texpected line: %s\n", game.FormatMoves(pv))
+		}
+	}
+}
+
+// flagSet is true if flag name is on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// inheritedVisits returns how many visits the tree p searched
+// for its latest move kept from earlier moves, if p is MCTS.
+func inheritedVisits(p players.Player) int {
+	if mcts, ok := p.(*players.MCTS); ok {
+		return mcts.InheritedVisits()
+	}
+	return 0
+}
+
+// openDB opens the solution database that perfect players look up moves in.
+func openDB(fileName string) *solution.DB {
+	db, err := solution.ReadDB(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
+
+// loadTablebase reads the endgame tablebase that alpha/beta
+// and MCTS players look up positions in.
+func loadTablebase(fileName string) *solution.Tablebase {
+	tb, err := solution.LoadTablebase(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return tb
+}
 
-	return createPlayer(firstType, maxDepth, deterministic, 500000),
-		createPlayer(secondType, maxDepth, deterministic, 500000)
+// evalOption returns the default option that has players value
+// positions with the Evaluator called name, none if name is empty.
+func evalOption(name string) string {
+	if name == "" {
+		return ""
+	}
+	if _, err := players.NewEvaluator(name, nil); err != nil {
+		log.Fatal(err)
+	}
+	return ",eval=" + name
 }
 
-func createPlayer(typ string, maxDepth int, deterministic bool, iterations int) players.Player {
+// usage adds the player types and their options to the flags.
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintf(flag.CommandLine.Output(), "\nPlayer types and options:\n%s", players.Usage())
+}
 
-	typ = strings.ToUpper(typ)
+// comparison has an alpha/beta player with some spec, and the same but
+// with plain alpha/beta search, choose moves in the same positions,
+// and counts the nodes each searches.
+type comparison struct {
+	names   [2]string
+	players [2]*players.AlphaBeta
+	nodes   [2]int
+	leaves  [2]int
+}
 
-	switch typ {
-	case "A":
-		return players.NewAlphaBeta(deterministic, maxDepth)
-	case "G":
-		ab := players.NewAlphaBeta(deterministic, maxDepth)
-		ab.SetAvoid()
-		return ab
-	case "M":
-		mcts := players.NewMCTS(iterations)
-		return mcts
-	case "U":
-		mcts := players.NewMCTS(iterations)
-		mcts.SetUCB1()
-		return mcts
+// newComparison makes the players to compare, exiting
+// if spec isn't an alpha/beta player's.
+func newComparison(spec string, env players.Env) *comparison {
+	if kind, err := players.Kind(spec); err != nil || kind != "ab" {
+		log.Fatalf("can only compare alpha/beta players, not %q", spec)
+	}
+	plain := spec + ",search=alphabeta"
+	if !strings.Contains(spec, ":") {
+		plain = spec + ":search=alphabeta"
 	}
+	cmp := &comparison{names: [2]string{"alpha/beta", spec}}
+	for k, s := range []string{plain, spec} {
+		player, err := players.NewPlayer(s, env)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cmp.players[k] = player.(*players.AlphaBeta)
+	}
+	return cmp
+}
 
-	return nil
+// search has both players choose a move in position bd, for
+// whichever side is to move, and prints what they found.
+func (cmp *comparison) search(bd *game.Position) {
+	if cmp == nil {
+		return
+	}
+
+	// Players' own marks are MAXIMIZER's
+	pos := game.MoverView(bd.History())
+
+	fmt.Printf("\t%d:", bd.MoveNumber())
+	for k, p := range cmp.players {
+		p.SetPosition(pos, MAXIMIZER)
+		x, y, value, leaves := p.ChooseMove()
+		cmp.nodes[k] += p.Nodes()
+		cmp.leaves[k] += leaves
+		fmt.Printf(" %s <%d,%d> (%d) %d nodes", cmp.names[k], x, y, value, p.Nodes())
+	}
+	fmt.Printf("\n")
+}
+
+// report prints the players' total node and leaf counts.
+func (cmp *comparison) report() {
+	if cmp == nil {
+		return
+	}
+	for k, name := range cmp.names {
+		fmt.Printf("%s: %d nodes, %d leaves\n", name, cmp.nodes[k], cmp.leaves[k])
+	}
+	if cmp.nodes[0] > 0 {
+		fmt.Printf("%s searched %.1f%% of the nodes %s did\n",
+			cmp.names[1], 100*float64(cmp.nodes[1])/float64(cmp.nodes[0]), cmp.names[0])
+	}
 }
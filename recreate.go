@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+
+	"squava2/game"
 	"squava2/mover"
 )
 
@@ -14,10 +16,7 @@ func main() {
 
 	markers := []rune{'O', '_', 'X'}
 
-	board := make([][]rune, 5)
-	for i := 0; i < 5; i++ {
-		board[i] = []rune{'_', '_', '_', '_', '_'}
-	}
+	board := game.NewPosition(game.MINIMIZER)
 
 	// Guess if game representation is in a file,
 	// or in command line string.
@@ -42,15 +41,14 @@ func main() {
 		}
 
 		fmt.Printf("%c move %d,%d\n", markers[player+1], n, m)
-		board[n][m] = markers[player+1]
+		board.MakeMove(game.Cell(n, m), player)
 
-		for i := 0; i < 5; i++ {
-			for _, marker := range board[i] {
-				fmt.Printf("%c ", marker)
-			}
-			fmt.Println()
+		fmt.Printf("%s\n", board)
+
+		if winner := board.Outcome(); winner != game.UNSET {
+			fmt.Printf("%c wins\n", markers[winner+1])
 		}
-		fmt.Println()
+
 		_, err := fmt.Scanf("\n")
 		if err != nil {
 			log.Print(err)
@@ -0,0 +1,170 @@
+package solution
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// DB file format: 8 magic bytes, then 8-byte little-endian records.
+// A record's low 50 bits are a game.Position CanonicalKey, the top
+// byte is the Value of that position. Records only ever get appended,
+// so an interrupted solve loses at most the record it was writing,
+// and reopening the file picks up where the solve left off.
+
+var magic = [8]byte{'S', 'Q', 'V', 'A', 'D', 'B', 0, 1}
+
+const keyMask = 1<<50 - 1
+
+type DB struct {
+	values  map[uint64]Value
+	deepest int // most moves made in any position in the database
+	file    *os.File
+	out     *bufio.Writer
+}
+
+// OpenDB reads all the records in file fileName, creating
+// it if it doesn't exist. Put appends records to the file.
+func OpenDB(fileName string) (*DB, error) {
+	file, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{
+		values: make(map[uint64]Value),
+		file:   file,
+	}
+
+	good, err := db.load()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+
+	// Drop any partial record at the end
+	if err := file.Truncate(good); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(good, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	db.out = bufio.NewWriter(file)
+
+	if good == 0 {
+		if _, err := db.out.Write(magic[:]); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := db.out.Flush(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// ReadDB reads all the records in file fileName, for players that
+// look positions up in it. It's an error if the file doesn't exist,
+// or has no records in it. Put on the DB it returns is an error.
+func ReadDB(fileName string) (*DB, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("solution database: %w", err)
+	}
+	defer file.Close()
+
+	db := &DB{values: make(map[uint64]Value), file: file}
+	if _, err := db.load(); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	if db.Len() == 0 {
+		return nil, fmt.Errorf("%s: no positions in the solution database, solve some first", fileName)
+	}
+	db.file = nil
+	return db, nil
+}
+
+// load reads records, returning the offset just past the last whole one.
+func (db *DB) load() (int64, error) {
+	in := bufio.NewReader(db.file)
+
+	var header [8]byte
+	if _, err := io.ReadFull(in, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, nil // new file
+		}
+		return 0, errors.New("short header")
+	}
+	if header != magic {
+		return 0, errors.New("not a squava solution database")
+	}
+
+	good := int64(len(magic))
+	var record [8]byte
+	for {
+		if _, err := io.ReadFull(in, record[:]); err != nil {
+			return good, nil
+		}
+		r := binary.LittleEndian.Uint64(record[:])
+		db.record(r&keyMask, Value(r>>56))
+		good += int64(len(record))
+	}
+}
+
+// Len returns the number of positions in the database
+func (db *DB) Len() int {
+	return len(db.values)
+}
+
+// Deepest returns the most moves made in any position in the
+// database. Positions with more moves made are never in it.
+func (db *DB) Deepest() int {
+	return db.deepest
+}
+
+// Get returns the value of the position with key, if known.
+func (db *DB) Get(key uint64) (Value, bool) {
+	v, ok := db.values[key]
+	return v, ok
+}
+
+// Put records the value of the position with key, and writes
+// it to the file before returning.
+func (db *DB) Put(key uint64, v Value) error {
+	if db.out == nil {
+		return errors.New("solution database is read only")
+	}
+	db.record(key, v)
+	var record [8]byte
+	binary.LittleEndian.PutUint64(record[:], key&keyMask|uint64(v)<<56)
+	if _, err := db.out.Write(record[:]); err != nil {
+		return err
+	}
+	return db.out.Flush()
+}
+
+func (db *DB) record(key uint64, v Value) {
+	db.values[key] = v
+	if moves := bits.OnesCount64(key); moves > db.deepest {
+		db.deepest = moves
+	}
+}
+
+func (db *DB) Close() error {
+	if db.file == nil {
+		return nil
+	}
+	if err := db.out.Flush(); err != nil {
+		db.file.Close()
+		return err
+	}
+	return db.file.Close()
+}
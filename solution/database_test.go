@@ -0,0 +1,93 @@
+package solution
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"squava2/game"
+)
+
+// lateGame is 12 moves into a game, X to move
+var lateGame = []int{24, 5, 13, 17, 10, 12, 16, 6, 7, 20, 21, 19}
+
+// solveInto solves lateGame, storing positions up to
+// 2 moves deeper in the database in file fileName.
+func solveInto(t *testing.T, fileName string) (Value, *Solver, int) {
+	t.Helper()
+	db, err := OpenDB(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	solver := NewSolver(db, len(lateGame)+2, 1<<20)
+	stored := 0
+	solver.Stored = func(*game.Position, Value) { stored++ }
+	v, err := solver.Solve(game.MoverView(lateGame))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v, solver, stored
+}
+
+func fileSize(t *testing.T, fileName string) int64 {
+	t.Helper()
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+// TestResumeSolved checks that solving again with the same
+// database looks the value up, writing nothing.
+func TestResumeSolved(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.db")
+	want, _, stored := solveInto(t, fileName)
+	if stored == 0 {
+		t.Fatal("solve stored no positions")
+	}
+	size := fileSize(t, fileName)
+
+	got, solver, stored := solveInto(t, fileName)
+	if got != want {
+		t.Errorf("solved again: %v, first time: %v", got, want)
+	}
+	if stored != 0 || solver.Nodes != 0 || fileSize(t, fileName) != size {
+		t.Errorf("solved again: %d positions stored, %d nodes searched, file size %d, was %d",
+			stored, solver.Nodes, fileSize(t, fileName), size)
+	}
+
+	db, err := ReadDB(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewSolver(db, len(lateGame)+2, 1<<20).Solve(game.MoverView(lateGame))
+	if err != nil || v != want {
+		t.Errorf("solved with read-only database: %v, %v, want %v", v, err, want)
+	}
+}
+
+// TestResumeInterrupted cuts a solved database off part way
+// through a record, the way an interrupted solve leaves it, and
+// checks that solving again only stores what got cut off.
+func TestResumeInterrupted(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.db")
+	want, _, total := solveInto(t, fileName)
+
+	kept := total / 2
+	if err := os.Truncate(fileName, int64(len(magic)+8*kept+3)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _, stored := solveInto(t, fileName)
+	if got != want {
+		t.Errorf("resumed: %v, first time: %v", got, want)
+	}
+	if stored != total-kept {
+		t.Errorf("resumed: stored %d positions, want the %d cut off", stored, total-kept)
+	}
+	if size := fileSize(t, fileName); size != int64(len(magic)+8*total) {
+		t.Errorf("resumed: file size %d, want %d", size, len(magic)+8*total)
+	}
+}
@@ -0,0 +1,191 @@
+package solution
+
+import (
+	"math/bits"
+
+	"squava2/game"
+)
+
+// Solver does an exact negamax alpha-beta search to the end
+// of the game, only searching one move of each class of symmetric
+// moves. Positions with no more than storeDepth moves made get
+// solved exactly and their values go in the database. Deeper
+// positions get a memory-bounded table of bounds on their scores.
+// A Solver with a nil database, or a negative storeDepth,
+// stores nothing.
+type Solver struct {
+	db         *DB
+	storeDepth int
+	table      []tableSlot
+	mask       uint64
+	err        error // first database write error
+	Nodes      int
+
+	// Stored, if not nil, gets called every time the Solver
+	// puts a position's value in the database.
+	Stored func(pos *game.Position, v Value)
+}
+
+// tableSlot holds bounds on the score of the position
+// with a key, key 0 meaning an empty slot.
+type tableSlot struct {
+	key          uint64
+	lower, upper int8
+}
+
+const usedSlot = 1 << 63
+
+// NewSolver makes a Solver that puts the values of positions with
+// up to storeDepth moves made into db, and uses up to tableBudget
+// bytes of memory for its table of deeper positions.
+func NewSolver(db *DB, storeDepth int, tableBudget int) *Solver {
+	size := 1
+	for size*2*16 <= tableBudget {
+		size *= 2
+	}
+	return &Solver{
+		db:         db,
+		storeDepth: storeDepth,
+		table:      make([]tableSlot, size),
+		mask:       uint64(size - 1),
+	}
+}
+
+// Solve returns the value of pos for the player to move.
+// Nobody can have won or lost in pos already.
+func (s *Solver) Solve(pos *game.Position) (Value, error) {
+	if pos.MoveNumber() >= 25 {
+		return MakeValue(Draw, 0), nil
+	}
+	if v, ok := s.Lookup(pos); ok {
+		return v, nil
+	}
+	score := s.search(pos, -winScore, winScore)
+	return FromScore(score, 25-pos.MoveNumber()), s.err
+}
+
+// Lookup returns the value of pos for the player to move
+// if it's in the database.
+func (s *Solver) Lookup(pos *game.Position) (Value, bool) {
+	if s.db == nil || pos.MoveNumber() > s.db.Deepest() {
+		return Unknown, false
+	}
+	return s.db.Get(pos.CanonicalKey())
+}
+
+// parentScore turns the score of the position after a move into the
+// score of the move for the player who made it: win becomes loss,
+// loss becomes win, and both are one move further from the end.
+func parentScore(score int) int {
+	switch {
+	case score > 0:
+		return -score + 1
+	case score < 0:
+		return -score - 1
+	}
+	return 0
+}
+
+// search returns the score of pos for the player to move, or a bound
+// on it outside (alpha, beta). pos has empty cells, and nobody has won.
+func (s *Solver) search(pos *game.Position, alpha, beta int) int {
+	s.Nodes++
+	player := pos.ToMove()
+
+	// A win on this move is as good as it gets
+	for empty := pos.Empty(); empty != 0; empty &= empty - 1 {
+		cell := bits.TrailingZeros32(empty)
+		pos.Make(cell)
+		outcome := pos.OutcomeAt(cell)
+		pos.Unmake()
+		if outcome == player {
+			return winScore - 1
+		}
+	}
+
+	if v, ok := s.Lookup(pos); ok {
+		return v.Score()
+	}
+
+	key := pos.CanonicalKey()
+	stored := s.db != nil && pos.MoveNumber() <= s.storeDepth
+	var slot *tableSlot
+
+	if stored {
+		// Solve it exactly, it goes in the database
+		alpha, beta = -winScore, winScore
+	} else {
+		slot = &s.table[(key*0x9e3779b97f4a7c15>>20)&s.mask]
+		if slot.key == key|usedSlot {
+			lower, upper := int(slot.lower), int(slot.upper)
+			if lower >= beta {
+				return lower
+			}
+			if upper <= alpha {
+				return upper
+			}
+			if lower == upper {
+				return lower
+			}
+			if lower > alpha {
+				alpha = lower
+			}
+			if upper < beta {
+				beta = upper
+			}
+		}
+	}
+
+	alphaOrig, betaOrig := alpha, beta
+	best := -winScore
+
+	for _, class := range pos.MoveClasses() {
+		cell := class[0]
+		var score int
+		pos.Make(cell)
+		switch {
+		case pos.OutcomeAt(cell) == -player:
+			score = -winScore + 1
+		case pos.MoveNumber() == 25:
+			score = 0 // Cat got the game
+		default:
+			// parentScore changes scores by 1, so widen the window by 1
+			score = parentScore(s.search(pos, -beta-1, -alpha+1))
+		}
+		pos.Unmake()
+
+		if score > best {
+			best = score
+			if best > alpha {
+				alpha = best
+			}
+			if alpha >= beta {
+				break
+			}
+		}
+	}
+
+	if stored {
+		v := FromScore(best, 25-pos.MoveNumber())
+		if err := s.db.Put(key, v); err != nil && s.err == nil {
+			s.err = err
+		}
+		if s.Stored != nil {
+			s.Stored(pos, v)
+		}
+		return best
+	}
+
+	lower, upper := -winScore, winScore
+	switch {
+	case best <= alphaOrig:
+		upper = best
+	case best >= betaOrig:
+		lower = best
+	default:
+		lower, upper = best, best
+	}
+	*slot = tableSlot{key: key | usedSlot, lower: int8(lower), upper: int8(upper)}
+
+	return best
+}
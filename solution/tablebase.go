@@ -0,0 +1,269 @@
+package solution
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"sort"
+
+	"squava2/game"
+)
+
+// Tablebase holds the values of every position that a game can
+// reach with up to some number of empty cells left, nobody having
+// won yet. Only the position with the smallest Key of each group of
+// symmetric positions is in it.
+type Tablebase struct {
+	// levels[e] holds records, key | value<<56, of the
+	// positions with e empty cells, in key order.
+	levels [][]uint64
+}
+
+// MaxTablebaseEmpty is the most empty cells a tablebase can have:
+// a record in a tablebase file only has room for distances up to 15.
+// Tablebases that big aren't practical: each empty cell more takes
+// about 5 times the time and memory, and 6 empty cells already
+// hold 24.7 million positions, 8 bytes each in memory.
+const MaxTablebaseEmpty = 15
+
+// Tablebase file format: 8 magic bytes, a byte holding the most
+// empty cells of any position, then for each number of empty cells,
+// 1 and up, a 4-byte little-endian count of records, and the records.
+// A record is 7 little-endian bytes: a game.Position CanonicalKey in
+// the low 50 bits, the result in the next 2, the distance in the top 4.
+
+var tablebaseMagic = [8]byte{'S', 'Q', 'V', 'A', 'T', 'B', 0, 1}
+
+const recordSize = 7
+
+// GenerateTablebase finds the values of all positions a game can reach
+// with from 1 to maxEmpty empty cells, fewest empty cells first, so that
+// the value of every move's position is known when it's needed.
+// If progress isn't nil, it gets called after each number of empty cells.
+func GenerateTablebase(maxEmpty int, progress func(empty int, count int)) (*Tablebase, error) {
+	if maxEmpty < 0 || maxEmpty > MaxTablebaseEmpty {
+		return nil, fmt.Errorf("tablebase can't have %d empty cells, 0 to %d", maxEmpty, MaxTablebaseEmpty)
+	}
+
+	tb := &Tablebase{levels: make([][]uint64, maxEmpty+1)}
+
+	for empty := 1; empty <= maxEmpty; empty++ {
+		records := enumerate(empty)
+		sort.Slice(records, func(i, j int) bool { return records[i] < records[j] })
+		for i, key := range records {
+			records[i] = key | uint64(tb.value(key, empty))<<56
+		}
+		tb.levels[empty] = records
+		if progress != nil {
+			progress(empty, len(records))
+		}
+	}
+
+	return tb, nil
+}
+
+// enumerate returns the canonical keys of every position with empty
+// empty cells that a game can reach without anyone winning or losing.
+// The player to move has made as many moves as the other player, or
+// one fewer, and neither player has 3 marks in a row: squava has no
+// 4 in a row without 3 in a row.
+func enumerate(empty int) []uint64 {
+	moves := 25 - empty
+	var keys []uint64
+	var mark func(cell int, mine, theirs uint32, mineLeft, theirsLeft, emptyLeft int)
+
+	mark = func(cell int, mine, theirs uint32, mineLeft, theirsLeft, emptyLeft int) {
+		if cell == 25 {
+			key := uint64(mine) | uint64(theirs)<<25
+			if game.Canonical(key) == key {
+				keys = append(keys, key)
+			}
+			return
+		}
+		bit := uint32(1) << cell
+		if emptyLeft > 0 {
+			mark(cell+1, mine, theirs, mineLeft, theirsLeft, emptyLeft-1)
+		}
+		if mineLeft > 0 && !hasTriplet(mine|bit, cell) {
+			mark(cell+1, mine|bit, theirs, mineLeft-1, theirsLeft, emptyLeft)
+		}
+		if theirsLeft > 0 && !hasTriplet(theirs|bit, cell) {
+			mark(cell+1, mine, theirs|bit, mineLeft, theirsLeft-1, emptyLeft)
+		}
+	}
+
+	mark(0, 0, 0, moves/2, moves-moves/2, empty)
+
+	return keys
+}
+
+func hasTriplet(marks uint32, cell int) bool {
+	for _, m := range game.TripletMasksAt[cell] {
+		if marks&m == m {
+			return true
+		}
+	}
+	return false
+}
+
+func hasQuad(marks uint32, cell int) bool {
+	for _, m := range game.QuadMasksAt[cell] {
+		if marks&m == m {
+			return true
+		}
+	}
+	return false
+}
+
+// value finds the value of the position with key and empty empty
+// cells, for the player to move, from the values of the positions
+// with one fewer empty cell.
+func (tb *Tablebase) value(key uint64, empty int) Value {
+	mine, theirs := uint32(key)&game.AllCells, uint32(key>>25)&game.AllCells
+	best := -winScore
+
+	for open := game.AllCells &^ (mine | theirs); open != 0; open &= open - 1 {
+		cell := bits.TrailingZeros32(open)
+		after := mine | 1<<cell
+
+		var score int
+		switch {
+		case hasQuad(after, cell):
+			score = winScore - 1
+		case hasTriplet(after, cell):
+			score = -winScore + 1
+		case empty == 1:
+			score = 0 // Cat got the game
+		default:
+			v, _ := tb.lookup(game.Canonical(uint64(theirs)|uint64(after)<<25), empty-1)
+			score = parentScore(v.Score())
+		}
+		if score > best {
+			best = score
+		}
+	}
+
+	return FromScore(best, empty)
+}
+
+// lookup finds the value of the position with canonical
+// key and empty empty cells.
+func (tb *Tablebase) lookup(key uint64, empty int) (Value, bool) {
+	records := tb.levels[empty]
+	i := sort.Search(len(records), func(i int) bool { return records[i]&keyMask >= key })
+	if i < len(records) && records[i]&keyMask == key {
+		return Value(records[i] >> 56), true
+	}
+	return Unknown, false
+}
+
+// MaxEmpty returns the most empty cells of
+// any position in the tablebase.
+func (tb *Tablebase) MaxEmpty() int {
+	return len(tb.levels) - 1
+}
+
+// Len returns the number of positions in the tablebase.
+func (tb *Tablebase) Len() int {
+	n := 0
+	for _, records := range tb.levels {
+		n += len(records)
+	}
+	return n
+}
+
+// Probe returns the value of pos for the player to move, if pos
+// has few enough empty cells to be in the tablebase, and nobody
+// has won or lost.
+func (tb *Tablebase) Probe(pos *game.Position) (Value, bool) {
+	empty := 25 - pos.MoveNumber()
+	if empty < 1 || empty >= len(tb.levels) {
+		return Unknown, false
+	}
+	return tb.lookup(pos.CanonicalKey(), empty)
+}
+
+// Write puts the tablebase in file fileName.
+func (tb *Tablebase) Write(fileName string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(file)
+
+	out.Write(tablebaseMagic[:])
+	out.WriteByte(byte(tb.MaxEmpty()))
+	var buf [8]byte
+	for _, records := range tb.levels[1:] {
+		binary.LittleEndian.PutUint32(buf[:], uint32(len(records)))
+		out.Write(buf[:4])
+		for _, r := range records {
+			v := Value(r >> 56)
+			binary.LittleEndian.PutUint64(buf[:], r&keyMask|uint64(v.Result())<<50|uint64(v.Distance())<<52)
+			out.Write(buf[:recordSize])
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadTablebase reads a tablebase from file fileName.
+func LoadTablebase(fileName string) (*Tablebase, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tb, err := readTablebase(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return tb, nil
+}
+
+func readTablebase(in io.Reader) (*Tablebase, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(in, header[:]); err != nil {
+		return nil, errors.New("short header")
+	}
+	if header != tablebaseMagic {
+		return nil, errors.New("not a squava tablebase")
+	}
+	var buf [8]byte
+	if _, err := io.ReadFull(in, buf[:1]); err != nil {
+		return nil, errors.New("short header")
+	}
+	maxEmpty := int(buf[0])
+	if maxEmpty > MaxTablebaseEmpty {
+		return nil, fmt.Errorf("bad number of empty cells %d", maxEmpty)
+	}
+
+	tb := &Tablebase{levels: make([][]uint64, maxEmpty+1)}
+	for empty := 1; empty <= maxEmpty; empty++ {
+		if _, err := io.ReadFull(in, buf[:4]); err != nil {
+			return nil, fmt.Errorf("%d empty cells: %w", empty, err)
+		}
+		records := make([]uint64, binary.LittleEndian.Uint32(buf[:4]))
+		buf = [8]byte{}
+		for i := range records {
+			if _, err := io.ReadFull(in, buf[:recordSize]); err != nil {
+				return nil, fmt.Errorf("%d empty cells: %w", empty, err)
+			}
+			r := binary.LittleEndian.Uint64(buf[:])
+			v := MakeValue(int(r>>50&3), int(r>>52&0xf))
+			records[i] = r&keyMask | uint64(v)<<56
+		}
+		tb.levels[empty] = records
+	}
+
+	return tb, nil
+}
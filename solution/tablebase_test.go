@@ -0,0 +1,46 @@
+package solution
+
+import (
+	"math/rand"
+	"testing"
+
+	"squava2/game"
+)
+
+// TestTablebaseMatchesSolver checks tablebase values, results and
+// distances, against the Solver's, for random positions in a small
+// tablebase, each turned by a random symmetry so that Probe has to
+// find the canonical one.
+func TestTablebaseMatchesSolver(t *testing.T) {
+	const maxEmpty, perLevel = 4, 200
+
+	tb, err := GenerateTablebase(maxEmpty, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := NewSolver(nil, -1, 1<<20)
+	rng := rand.New(rand.NewSource(1))
+
+	for empty := 1; empty <= maxEmpty; empty++ {
+		records := tb.levels[empty]
+		for n := 0; n < perLevel; n++ {
+			key := records[rng.Intn(len(records))] & keyMask
+			sym := rng.Intn(len(game.Symmetries))
+			mine := game.TransformMarks(sym, uint32(key)&game.AllCells)
+			theirs := game.TransformMarks(sym, uint32(key>>25)&game.AllCells)
+			pos := game.FromKey(uint64(mine)|uint64(theirs)<<25, game.MAXIMIZER)
+
+			got, ok := tb.Probe(pos)
+			if !ok {
+				t.Fatalf("position not in the tablebase:\n%s", pos)
+			}
+			want, err := solver.Solve(pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("tablebase has %v, solver finds %v, for:\n%s", got, want, pos)
+			}
+		}
+	}
+}
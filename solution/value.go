@@ -0,0 +1,87 @@
+// Package solution finds the game-theoretic value of squava
+// positions: win, loss or draw for the player to move, and how
+// many more moves until the game ends with perfect play. It keeps
+// the values of positions in an on-disk database, so that a long
+// solve can stop and resume, and so players can look values up.
+package solution
+
+import "fmt"
+
+// Results, from the point of view of the player to move
+const (
+	Unknown = iota
+	Win
+	Loss
+	Draw
+)
+
+// Value packs a result and a distance, the number of moves
+// until the game ends with perfect play, into a byte.
+// Top 2 bits are the result, low 6 bits the distance.
+type Value uint8
+
+func MakeValue(result int, distance int) Value {
+	return Value(result<<6 | distance)
+}
+
+func (v Value) Result() int {
+	return int(v >> 6)
+}
+
+func (v Value) Distance() int {
+	return int(v & 0x3f)
+}
+
+func (v Value) String() string {
+	switch v.Result() {
+	case Win:
+		return fmt.Sprintf("win in %d", v.Distance())
+	case Loss:
+		return fmt.Sprintf("loss in %d", v.Distance())
+	case Draw:
+		return fmt.Sprintf("draw in %d", v.Distance())
+	}
+	return "unknown"
+}
+
+// Scores order values for the player to move: faster wins
+// score higher, slower losses score higher than faster
+// losses. Any win beats a draw, a draw beats any loss.
+const winScore = 100
+
+// Score turns v into a number, bigger is better for the player to move.
+func (v Value) Score() int {
+	switch v.Result() {
+	case Win:
+		return winScore - v.Distance()
+	case Loss:
+		return -winScore + v.Distance()
+	}
+	return 0
+}
+
+// FromScore turns a score back into a Value. Draws get the
+// distance of empty cells, since a drawn game fills the board.
+func FromScore(score int, empties int) Value {
+	switch {
+	case score > 0:
+		return MakeValue(Win, winScore-score)
+	case score < 0:
+		return MakeValue(Loss, winScore+score)
+	}
+	return MakeValue(Draw, empties)
+}
+
+// Parent returns the value, to the player who made the move, of
+// a move that leads to a position with value v for the other player.
+func (v Value) Parent() Value {
+	switch v.Result() {
+	case Win:
+		return MakeValue(Loss, v.Distance()+1)
+	case Loss:
+		return MakeValue(Win, v.Distance()+1)
+	case Draw:
+		return MakeValue(Draw, v.Distance()+1)
+	}
+	return v
+}
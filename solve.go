@@ -0,0 +1,101 @@
+package main
+
+/*
+ * Solve squava: find the value, win, loss or draw for the player
+ * to move, of the empty board or of a partial game, by searching
+ * every line of play to the end. Values of positions with few enough
+ * moves made go into a database file. Running the program again with
+ * the same database file resumes an interrupted solve, and looks
+ * up whatever got solved before.
+ */
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"squava2/game"
+	"squava2/mover"
+	"squava2/solution"
+)
+
+func main() {
+	dbName := flag.String("f", "squava.db", "solution database file")
+	storeDepth := flag.Int("s", 6, "store values of positions with up to this many moves made")
+	tableSize := flag.Int("T", 256, "table size for deeper positions, MB")
+	partialGame := flag.String("p", "", "partial game to solve, filename or comma-sep move string")
+	flag.Parse()
+
+	db, err := solution.OpenDB(*dbName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d positions in %s\n", db.Len(), *dbName)
+
+	// Every value gets written as soon as it's known,
+	// so there's nothing to save on interrupt.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		fmt.Printf("\ninterrupted, %d positions in %s, run again to resume\n", db.Len(), *dbName)
+		os.Exit(1)
+	}()
+
+	pos := game.NewPosition(game.MAXIMIZER)
+	if *partialGame != "" {
+		gameSoFar(*partialGame, pos)
+	}
+	fmt.Printf("%s\n", pos)
+	if winner := pos.Outcome(); winner != game.UNSET {
+		log.Fatalf("game already over, %c won\n", "O_X"[winner+1])
+	}
+
+	root := pos.MoveNumber()
+	solver := solution.NewSolver(db, *storeDepth, *tableSize<<20)
+	solver.Stored = func(p *game.Position, v solution.Value) {
+		if p.MoveNumber() == root+1 {
+			x, y := game.Coords(p.LastMove())
+			fmt.Printf("move %d,%d: %v for the mover, %d positions stored\n", x, y, v.Parent(), db.Len())
+		}
+	}
+
+	start := time.Now()
+	value, err := solver.Solve(pos)
+	if err != nil {
+		log.Fatal(err)
+	}
+	et := time.Since(start)
+
+	fmt.Printf("%c to move: %v\n", "O_X"[pos.ToMove()+1], value)
+	fmt.Printf("%d nodes, %d positions stored, %v\n", solver.Nodes, db.Len(), et)
+
+	if err := db.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// gameSoFar makes the moves of a partial game on pos,
+// players alternating, X first.
+func gameSoFar(partial string, pos *game.Position) {
+	var moves *mover.Mvr
+
+	if _, err := os.Stat(partial); err == nil {
+		moves = mover.NewFromFile(partial)
+	} else {
+		moves = mover.NewFromBuffer([]byte(partial))
+	}
+
+	moves.NextPlayer(game.MAXIMIZER)
+
+	for {
+		player, x, y, counter, useIt := moves.Next()
+		if !useIt || counter > 24 {
+			break
+		}
+		pos.MakeMove(game.Cell(x, y), player)
+	}
+}
@@ -1,16 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"io"
+	"log"
 	"math/rand"
 	"os"
 	"strings"
 	"time"
 
+	"squava2/clock"
+	"squava2/game"
 	"squava2/mover"
 	"squava2/players"
+	"squava2/solution"
 )
 
 const (
@@ -21,19 +27,62 @@ const (
 func main() {
 
 	computerFirstPtr := flag.Bool("C", false, "Computer takes first move (default false)")
-	maxDepthPtr := flag.Int("d", 10, "maximum lookahead depth (alpha/beta)")
-	typ := flag.String("t", "A", "player type, A: alphabeta, G: A/B+avoid bad positions, M: MCTS/Plain, U: MCTS/UCB1")
+	maxDepthPtr := flag.Int("d", 0, "maximum lookahead depth, 0: by move number (alpha/beta)")
+	typ := flag.String("t", "A", "player spec, like A, G, U, mcts:ucb1,iters=200000 or ab:eval=avoid,depth=10")
 	i := flag.Int("i", 500000, "MCTS iterations")
 	partialGame := flag.String("p", "", "partial game, filename or comma-sep move string")
+	tableSize := flag.Int("T", players.DefaultTableSize>>20, "transposition table size, MB (alpha/beta)")
+	moveTime := flag.Duration("m", 0, "time per move, search deeper until it runs out (alpha/beta)")
+	dbName := flag.String("f", "squava.db", "solution database file (perfect)")
+	tbName := flag.String("b", "", "endgame tablebase file (alpha/beta, MCTS)")
+	evalName := flag.String("E", "", "evaluator for players that use one, unless their specs say, like basic, avoid or block (alpha/beta, MCTS evaluator playouts)")
+	workers := flag.Int("w", 0, "worker goroutines per search, 0 for a plain serial search (MCTS, alpha/beta)")
+	timeControl := flag.String("tc", "", "time control for both players, like 5m, 5m+2s or 2s/move, running out loses")
+	ponder := flag.Bool("P", false, "ponder: the computer thinks while you do (alpha/beta, MCTS)")
+	flag.Usage = usage
 	flag.Parse()
 
 	rand.Seed(time.Now().UTC().UnixNano())
 
+	tc, err := clock.Parse(*timeControl)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	var winner int
 
-	moveCounter := 0
+	// With a clock, MCTS searches until its move's time runs out
+	iterations := *i
+	if !tc.None() && !flagSet("i") {
+		iterations = 0
+	}
+
+	env := players.Env{
+		Defaults: fmt.Sprintf("depth=%d,iters=%d,tt=%dMB,time=%v,threads=%d",
+			*maxDepthPtr, iterations, *tableSize, *moveTime, *workers) + evalOption(*evalName),
+	}
+	if kind, err := players.Kind(*typ); err == nil && kind == "perfect" {
+		env.DB = openDB(*dbName)
+	}
+	if *tbName != "" {
+		env.Tablebase = loadTablebase(*tbName)
+	}
+	computerPlayer, err := players.NewPlayer(*typ, env)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if ab, ok := computerPlayer.(*players.AlphaBeta); ok {
+		// Show the line of play it expects
+		ab.SetRecordPV(true)
+	}
 
-	computerPlayer := createPlayer(*typ, *maxDepthPtr, *i)
+	var ponderer players.Ponderer
+	if *ponder {
+		var ok bool
+		if ponderer, ok = computerPlayer.(players.Ponderer); !ok {
+			log.Fatalf("%s players can't ponder", computerPlayer.Name())
+		}
+	}
 
 	next := HUMAN
 	if *computerFirstPtr {
@@ -42,11 +91,18 @@ func main() {
 
 	// computerPlayer keeps track of the board internally,
 	// but we'll keep track too, so the human can be informed
-	// that an input move has already been taken.
-	bd := new(Board)
+	// that an input move has already been taken, and so that
+	// the game gets refereed by the rules, not by the computer.
+	bd := game.NewPosition(next)
+
+	clocks := map[int]*clock.Clock{
+		COMPUTER: clock.New(tc),
+		HUMAN:    clock.New(tc),
+	}
 
 	if *partialGame != "" {
-		next = gameSoFar(next, *partialGame, bd, computerPlayer)
+		next = gameSoFar(next, *partialGame, bd)
+		computerPlayer.SetPosition(bd, next)
 		playerPhrase := "human"
 		if next == 1 {
 			playerPhrase = "computer"
@@ -56,90 +112,124 @@ func main() {
 		fmt.Printf("\nMy board:\n%s\n", bd)
 	}
 
-	for moveCounter < 25 {
+	for bd.MoveNumber() < 25 {
 
 		switch next {
 
 		case HUMAN:
-			l, m := bd.readMove()
+			if ponderer != nil {
+				ponderer.Ponder()
+			}
+			clocks[HUMAN].Start()
+			l, m, undo := readMove(bd)
+			if !clocks[HUMAN].Stop() {
+				break
+			}
+			if undo {
+				takeBack(bd, computerPlayer)
+				fmt.Printf("%s\n", computerPlayer)
+				continue
+			}
 			computerPlayer.MakeMove(l, m, HUMAN)
+			if ponderer != nil {
+				reportPonder(ponderer)
+			}
 			next = COMPUTER
 
 		case COMPUTER:
+			ctx, cancel := clocks[COMPUTER].Context(context.Background(), bd.MoveNumber())
 			before := time.Now()
-			i, j, value, leafCount := computerPlayer.ChooseMove()
+			clocks[COMPUTER].Start()
+			i, j, value, leafCount, _ := computerPlayer.ChooseMoveContext(ctx)
+			clocks[COMPUTER].Stop()
 			et := time.Since(before)
+			cancel()
 
 			fmt.Printf("X (%s) <%d,%d> (%d) [%d] %v\n", computerPlayer.Name(), i, j, value, leafCount, et)
-
-			bd.makeMove(i, j, COMPUTER)
+			if ab, ok := computerPlayer.(*players.AlphaBeta); ok {
+				hits, misses := ab.TableStats()
+				fmt.Printf("nodes %d, transposition table hits %d, misses %d\n", ab.Nodes(), hits, misses)
+				if pv := ab.PrincipalVariation(); len(pv) > 1 {
+					fmt.Printf("expected line: %s\n", game.FormatMoves(pv))
+				}
+			}
+			if mcts, ok := computerPlayer.(*players.MCTS); ok {
+				fmt.Printf("visits inherited from earlier moves %d\n", mcts.InheritedVisits())
+			}
+
+			bd.MakeMove(game.Cell(i, j), COMPUTER)
 			next = HUMAN
 		}
 
-		moveCounter++
-		winner = computerPlayer.FindWinner()
+		winner = bd.Outcome()
+
+		if !tc.None() {
+			fmt.Printf("Clocks: X %v, O %v\n", clocks[COMPUTER], clocks[HUMAN])
+		}
+		if flagged := outOfTime(clocks); flagged != 0 {
+			winner = -flagged
+			break
+		}
 
-		if winner != 0 || moveCounter >= 25 {
+		if bd.Finished() {
 			break
 		}
 
 		fmt.Printf("%s\n", computerPlayer)
 	}
 
+	onTime := ""
+	if outOfTime(clocks) != 0 {
+		onTime = " on time"
+	}
+
 	switch winner {
 	case 1:
-		fmt.Printf("player 1 X (%s) wins\n", computerPlayer.Name())
+		fmt.Printf("player 1 X (%s) wins%s\n", computerPlayer.Name(), onTime)
 	case -1:
-		fmt.Printf("player 2 O (human) wins\n")
+		fmt.Printf("player 2 O (human) wins%s\n", onTime)
 	default:
 		fmt.Printf("Cat wins\n")
 	}
 
+	if ponderer != nil {
+		hits, misses := ponderer.PonderStats()
+		fmt.Printf("ponder hits %d, misses %d\n", hits, misses)
+	}
+
 	fmt.Printf("%s\n", computerPlayer)
 }
 
-func createPlayer(typ string, maxDepth int, iterations int) players.Player {
-
-	typ = strings.ToUpper(typ)
-
-	switch typ {
-	case "A":
-		return players.NewAlphaBeta(false, maxDepth)
-	case "G":
-		ab := players.NewAlphaBeta(false, maxDepth)
-		ab.SetAvoid()
-		return ab
-	case "M":
-		mcts := players.NewMCTS(iterations)
-		mcts.SetIterations(iterations)
-		return mcts
-	case "U":
-		mcts := players.NewMCTS(iterations)
-		mcts.SetIterations(iterations)
-		mcts.SetUCB1()
-		return mcts
-	}
-
-	return nil
+// reportPonder says whether the computer pondered on the move
+// the human just made, if it pondered at all.
+func reportPonder(ponderer players.Ponderer) {
+	hits, misses := ponderer.PonderStats()
+	switch {
+	case hits > lastPonderHits:
+		fmt.Printf("ponder hit\n")
+	case misses > lastPonderMisses:
+		fmt.Printf("ponder miss\n")
+	}
+	lastPonderHits, lastPonderMisses = hits, misses
 }
 
-// This program's board representation.
-// Chosen for ease of human use rather than
-// speed or compactness.
-type Board [5][5]int
-
-func (bd *Board) makeMove(x, y, player int) {
-	bd[x][y] = player
-}
+var lastPonderHits, lastPonderMisses int
 
-func (bd *Board) readMove() (x, y int) {
+// readMove gets a move from the human, and marks it on
+// this program's board, checking for cells already taken.
+// An input line of "u" takes back the human's last move instead.
+func readMove(bd *game.Position) (x, y int, undo bool) {
 	readMove := false
 	for !readMove {
 		fmt.Printf("Your move: ")
-		_, err := fmt.Scanf("%d %d\n", &x, &y)
-		if err == io.EOF {
+		line, err := stdin.ReadString('\n')
+		if err == io.EOF && line == "" {
 			os.Exit(0)
 		}
+		if strings.TrimSpace(line) == "u" {
+			return 0, 0, true
+		}
+		_, err = fmt.Sscanf(line, "%d %d", &x, &y)
 		if err != nil {
 			fmt.Printf("Failed to read: %v\n", err)
 			continue
@@ -147,31 +237,61 @@ func (bd *Board) readMove() (x, y int) {
 		switch {
 		case x < 0 || x > 4 || y < 0 || y > 4:
 			fmt.Printf("Choose two numbers between 0 and 4, try again\n")
-		case bd[x][y] == 0:
+		case bd.At(game.Cell(x, y)) == game.UNSET:
 			readMove = true
-		case bd[x][y] != 0:
+		default:
 			fmt.Printf("Cell (%d, %d) already occupied, try again\n", x, y)
 		}
 	}
-	bd.makeMove(x, y, HUMAN)
-	return x, y
+	bd.MakeMove(game.Cell(x, y), HUMAN)
+	return x, y, false
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// takeBack takes back the human's last move, and the computer's
+// moves since, on both bd and the computer's board.
+func takeBack(bd *game.Position, computerPlayer players.Player) {
+	humanMoved := false
+	for _, cell := range bd.History() {
+		humanMoved = humanMoved || bd.At(cell) == HUMAN
+	}
+	if !humanMoved {
+		fmt.Printf("No move of yours to take back\n")
+		return
+	}
+	for {
+		player := bd.At(bd.LastMove())
+		bd.Unmake()
+		computerPlayer.UnmakeMove()
+		if player == HUMAN {
+			return
+		}
+	}
 }
 
-func (bd *Board) String() string {
-	buf := &strings.Builder{}
-	buf.WriteString("   0 1 2 3 4")
-	markers := []rune{'O', '_', 'X'}
-	for n, row := range bd {
-		buf.WriteString("\n")
-		fmt.Fprintf(buf, "%d  ", n)
-		for _, player := range row {
-			fmt.Fprintf(buf, "%c ", markers[player+1])
+// outOfTime returns the player whose clock ran out, if either did.
+func outOfTime(clocks map[int]*clock.Clock) int {
+	for player, c := range clocks {
+		if c.Flagged() {
+			return player
 		}
 	}
-	return buf.String()
+	return 0
+}
+
+// flagSet is true if flag name is on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
 }
 
-func gameSoFar(firstPlayer int, partial string, bd *Board, p players.Player) int {
+// gameSoFar makes the moves of partial on bd, and
+// returns the player who makes the next move.
+func gameSoFar(firstPlayer int, partial string, bd *game.Position) int {
 
 	var moves *mover.Mvr
 
@@ -191,10 +311,47 @@ func gameSoFar(firstPlayer int, partial string, bd *Board, p players.Player) int
 		if !useIt || counter > 24 {
 			break
 		}
-		(*bd)[n][m] = player
-		p.MakeMove(n, m, player)
+		bd.MakeMove(game.Cell(n, m), player)
 		next = player
 	}
 
 	return 0 - next
 }
+
+// openDB opens the solution database that perfect players look up moves in.
+func openDB(fileName string) *solution.DB {
+	db, err := solution.ReadDB(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
+
+// loadTablebase reads the endgame tablebase that alpha/beta
+// and MCTS players look up positions in.
+func loadTablebase(fileName string) *solution.Tablebase {
+	tb, err := solution.LoadTablebase(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return tb
+}
+
+// evalOption returns the default option that has players value
+// positions with the Evaluator called name, none if name is empty.
+func evalOption(name string) string {
+	if name == "" {
+		return ""
+	}
+	if _, err := players.NewEvaluator(name, nil); err != nil {
+		log.Fatal(err)
+	}
+	return ",eval=" + name
+}
+
+// usage adds the player types and their options to the flags.
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintf(flag.CommandLine.Output(), "\nPlayer types and options:\n%s", players.Usage())
+}
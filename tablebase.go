@@ -0,0 +1,37 @@
+package main
+
+/*
+ * Generate an endgame tablebase: the value of every position
+ * a game of squava can reach with a few empty cells left.
+ * Alpha-beta and MCTS players look positions up in it, so
+ * they play the end of a game perfectly.
+ */
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"squava2/solution"
+)
+
+func main() {
+	maxEmpty := flag.Int("n", 5, "most empty cells of positions in the tablebase: each one more takes about 5 times the time and memory, 6 takes about a minute and 200 MB")
+	fileName := flag.String("f", "squava.tb", "tablebase file")
+	flag.Parse()
+
+	start := time.Now()
+
+	tb, err := solution.GenerateTablebase(*maxEmpty, func(empty int, count int) {
+		fmt.Printf("%d empty cells: %d positions, %v\n", empty, count, time.Since(start))
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := tb.Write(*fileName); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d positions in %s, %v\n", tb.Len(), *fileName, time.Since(start))
+}